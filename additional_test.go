@@ -0,0 +1,134 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// additionalPropertiesStates pins the emitted JSON and the validation of
+// every state of additionalProperties. A nil emitted value means the
+// keyword must be absent, which is what tells unset apart from false.
+var additionalPropertiesStates = []struct {
+	name                 string
+	spec                 []string
+	additionalProperties ojson.Anything
+	emitted              ojson.Anything
+	validationCases      []validationCase
+}{
+	{
+		name:                 "unset",
+		spec:                 []string{"core §9.3.2.1 properties", "core §9.3.2.3 additionalProperties"},
+		additionalProperties: nil,
+		emitted:              nil,
+		validationCases: []validationCase{
+			{
+				name:     "additional integer",
+				actual:   ojson.Object{"field": "hello", "extra": 42},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "declared property integer",
+				actual: ojson.Object{"field": 42},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/field", InvalidValue: 42, Message: "type should be string, got integer"},
+				},
+			},
+		},
+	},
+	{
+		name:                 "true",
+		spec:                 []string{"core §9.3.2.1 properties", "core §9.3.2.3 additionalProperties"},
+		additionalProperties: true,
+		emitted:              true,
+		validationCases: []validationCase{
+			{
+				name:     "additional integer",
+				actual:   ojson.Object{"field": "hello", "extra": 42},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "declared property integer",
+				actual: ojson.Object{"field": 42},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/field", InvalidValue: 42, Message: "type should be string, got integer"},
+				},
+			},
+		},
+	},
+	{
+		name:                 "false",
+		spec:                 []string{"core §9.3.2.1 properties", "core §9.3.2.3 additionalProperties"},
+		additionalProperties: false,
+		emitted:              false,
+		validationCases: []validationCase{
+			{
+				name:     "declared property only",
+				actual:   ojson.Object{"field": "hello"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "additional integer",
+				actual: ojson.Object{"field": "hello", "extra": 42},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"field": "hello", "extra": 42},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+		},
+	},
+	{
+		name:                 "subschema",
+		spec:                 []string{"core §9.3.2.1 properties", "core §9.3.2.3 additionalProperties"},
+		additionalProperties: ojsonschema.String{},
+		emitted:              mustUnmarshal(string(ojson.MustMarshal(ojsonschema.String{}))),
+		validationCases: []validationCase{
+			{
+				name:     "additional string",
+				actual:   ojson.Object{"field": "hello", "extra": "world"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "additional integer",
+				actual: ojson.Object{"field": "hello", "extra": 42},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/extra", InvalidValue: 42, Message: "type should be string, got integer"},
+				},
+			},
+		},
+	},
+}
+
+func TestAdditionalPropertiesStates(t *testing.T) {
+	for _, additionalPropertiesState := range additionalPropertiesStates {
+		t.Run(additionalPropertiesState.name, func(t *testing.T) {
+			schemaData := ojson.MustMarshal(ojsonschema.Object{
+				AdditionalProperties: additionalPropertiesState.additionalProperties,
+				Properties: ojson.Object{
+					"field": ojsonschema.String{},
+				},
+			})
+			members := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(schemaData, &members))
+			emitted, present := members["additionalProperties"]
+			require.Equal(t, additionalPropertiesState.emitted != nil, present, "unset must not be emitted: %s", schemaData)
+			require.Equal(t, additionalPropertiesState.emitted, emitted, string(schemaData))
+
+			schema := new(jsonschema.Schema)
+			require.NoError(t, json.Unmarshal(schemaData, schema))
+			for _, validationCase := range additionalPropertiesState.validationCases {
+				t.Run(validationCase.name, func(t *testing.T) {
+					state := schema.Validate(context.Background(), validationCase.actual)
+					require.Equal(t, validationCase.expected, *state.Errs)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// corpusDir holds JSON Schemas vendored verbatim from published packages,
+// one directory per package with its licence, see SOURCES.md there. Every
+// *.json file is a schema, except under examples/<schema>/, which holds
+// instances of <schema>.json from the same package.
+const corpusDir = "testdata/corpus"
+
+type corpusExample struct {
+	name string
+	data json.RawMessage
+}
+
+type corpusSchema struct {
+	name     string
+	data     []byte
+	examples []corpusExample
+}
+
+// loadCorpus reads every schema with its examples: the top-level
+// "examples" of the schema, the files under examples/<schema>/, and, for
+// a meta-schema, the corpus schemas whose "$schema" is its id.
+func loadCorpus() ([]corpusSchema, error) {
+	var corpus []corpusSchema
+	err := filepath.Walk(corpusDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "examples" {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(corpusDir, path)
+		if err != nil {
+			return err
+		}
+		corpusSchema := corpusSchema{name: filepath.ToSlash(name), data: data}
+		var members struct {
+			Examples interface{} `json:"examples"`
+		}
+		if err := json.Unmarshal(data, &members); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if examples, ok := members.Examples.([]interface{}); ok {
+			for i, example := range examples {
+				corpusSchema.examples = append(corpusSchema.examples, corpusExample{
+					name: fmt.Sprintf("examples/%d", i),
+					data: ojson.MustMarshal(example),
+				})
+			}
+		}
+		examplePaths, err := filepath.Glob(filepath.Join(filepath.Dir(path), "examples",
+			strings.TrimSuffix(filepath.Base(path), ".json"), "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, examplePath := range examplePaths {
+			example, err := os.ReadFile(examplePath)
+			if err != nil {
+				return err
+			}
+			corpusSchema.examples = append(corpusSchema.examples, corpusExample{
+				name: filepath.Base(examplePath),
+				data: example,
+			})
+		}
+		corpus = append(corpus, corpusSchema)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	metaSchemas := map[string]int{}
+	for i, corpusSchema := range corpus {
+		var members struct {
+			ID      string `json:"$id"`
+			DraftID string `json:"id"`
+		}
+		if err := json.Unmarshal(corpusSchema.data, &members); err == nil {
+			for _, id := range []string{members.ID, members.DraftID} {
+				if id != "" {
+					metaSchemas[strings.TrimSuffix(id, "#")] = i
+				}
+			}
+		}
+	}
+	for _, corpusSchema := range corpus {
+		var members struct {
+			Schema string `json:"$schema"`
+		}
+		if err := json.Unmarshal(corpusSchema.data, &members); err != nil {
+			continue
+		}
+		if i, ok := metaSchemas[strings.TrimSuffix(members.Schema, "#")]; ok {
+			corpus[i].examples = append(corpus[i].examples, corpusExample{
+				name: corpusSchema.name,
+				data: corpusSchema.data,
+			})
+		}
+	}
+	return corpus, nil
+}
+
+// errorKeywords tells the keyword behind a KeyError from its message, as
+// KeyError does not carry it. The patterns follow the messages of
+// qri-io/jsonschema v0.2.1; an unresolvable $ref is reported twice, the
+// second time as a nil schema.
+var errorKeywords = []struct {
+	keyword string
+	message *regexp.Regexp
+}{
+	{"type", regexp.MustCompile(`^type should be `)},
+	{"enum", regexp.MustCompile(`^should be one of `)},
+	{"const", regexp.MustCompile(`^must equal `)},
+	{"required", regexp.MustCompile(`^".*" value is required$`)},
+	{"dependentRequired", regexp.MustCompile(`^".*" property is required$`)},
+	{"additionalProperties", regexp.MustCompile(`^additional properties are not allowed$`)},
+	{"unevaluatedProperties", regexp.MustCompile(`^unevaluated properties are not allowed$`)},
+	{"minimum", regexp.MustCompile(`^must be greater than or equal to `)},
+	{"exclusiveMinimum", regexp.MustCompile(`^\S+ must be greater than `)},
+	{"maximum", regexp.MustCompile(`^must be less than or equal to `)},
+	{"exclusiveMaximum", regexp.MustCompile(`^\S+ must be less than `)},
+	{"multipleOf", regexp.MustCompile(`^must be a multiple of `)},
+	{"minLength", regexp.MustCompile(`^min length of \d+ characters required: `)},
+	{"maxLength", regexp.MustCompile(`^max length of \d+ characters exceeded: `)},
+	{"pattern", regexp.MustCompile(`^regexp pattern .* mismatch on string: `)},
+	{"minItems", regexp.MustCompile(`^array length \d+ below \d+ minimum items$`)},
+	{"maxItems", regexp.MustCompile(`^array length \d+ exceeds \d+ max$`)},
+	{"uniqueItems", regexp.MustCompile(`^array items must be unique\. duplicated entry: `)},
+	{"contains", regexp.MustCompile(`^must contain at least one of: `)},
+	{"minContains", regexp.MustCompile(`^contained items \d+ bellow \d+ min$`)},
+	{"maxContains", regexp.MustCompile(`^contained items \d+ exceeds \d+ max$`)},
+	{"additionalItems", regexp.MustCompile(`^additional items are not allowed$`)},
+	{"unevaluatedItems", regexp.MustCompile(`^unevaluated items are not allowed$`)},
+	{"minProperties", regexp.MustCompile(`^\d+ object Properties below \d+ minimum$`)},
+	{"maxProperties", regexp.MustCompile(`^\d+ object Properties exceed \d+ maximum$`)},
+	{"anyOf", regexp.MustCompile(`^did Not match any specified AnyOf schemas$`)},
+	{"oneOf", regexp.MustCompile(`^(did not match any of the specified|matched more than one specified) OneOf schemas$`)},
+	{"not", regexp.MustCompile(`^result was valid, \('not'\) expected invalid$`)},
+	{"$ref", regexp.MustCompile(`^(failed to resolve schema for ref |schema is nil$)`)},
+	{"false schema", regexp.MustCompile(`^schema is always false$`)},
+	{"format", regexp.MustCompile(`^invalid \S+: `)},
+}
+
+func keywordOfError(err jsonschema.KeyError) string {
+	for _, errorKeyword := range errorKeywords {
+		if errorKeyword.message.MatchString(err.Message) {
+			return errorKeyword.keyword
+		}
+	}
+	return "other"
+}
+
+// TestCorpusCompiles unmarshals every schema, refusing duplicate keys.
+// Schemas qri cannot unmarshal are gaps, listed in corpusGapsPath.
+func TestCorpusCompiles(t *testing.T) {
+	corpus, err := loadCorpus()
+	require.NoError(t, err)
+	require.NotEmpty(t, corpus)
+	known, err := readCorpusGaps()
+	require.NoError(t, err)
+	for _, corpusSchema := range corpus {
+		t.Run(corpusSchema.name, func(t *testing.T) {
+			if err := unmarshalSchemaStrict(corpusSchema.data, new(jsonschema.Schema)); err != nil {
+				require.True(t, known[compileGap(corpusSchema.name, err)], "%s", err)
+			}
+		})
+	}
+}
+
+func compileGap(name string, err error) string {
+	return fmt.Sprintf("compile: %s: %s", name, err)
+}
+
+// subschemaKeywords are the keywords whose values are schemas, maps of
+// schemas or arrays of schemas, up to draft 2019-09.
+var subschemaKeywords = map[string]string{
+	"additionalItems": "schema", "additionalProperties": "schema", "contains": "schema",
+	"propertyNames": "schema", "not": "schema", "if": "schema", "then": "schema", "else": "schema",
+	"unevaluatedItems": "schema", "unevaluatedProperties": "schema",
+	"items": "schema or array", "allOf": "array", "anyOf": "array", "oneOf": "array",
+	"properties": "map", "patternProperties": "map", "dependencies": "map", "dependentSchemas": "map",
+	"definitions": "map", "$defs": "map",
+}
+
+// definitionsToDefs moves the "definitions" of a decoded schema and of its
+// subschemas to "$defs", and points the refs into them there, as qri
+// v0.2.1 only resolves refs into "$defs".
+func definitionsToDefs(schema interface{}) interface{} {
+	members, ok := schema.(map[string]interface{})
+	if !ok {
+		return schema
+	}
+	moved := map[string]interface{}{}
+	for keyword, value := range members {
+		switch subschemaKeywords[keyword] {
+		case "schema":
+			value = definitionsToDefs(value)
+		case "schema or array", "array":
+			if array, ok := value.([]interface{}); ok {
+				items := make([]interface{}, len(array))
+				for i, item := range array {
+					items[i] = definitionsToDefs(item)
+				}
+				value = items
+			} else {
+				value = definitionsToDefs(value)
+			}
+		case "map":
+			if object, ok := value.(map[string]interface{}); ok {
+				subschemas := map[string]interface{}{}
+				for key, subschema := range object {
+					subschemas[key] = definitionsToDefs(subschema)
+				}
+				value = subschemas
+			}
+		}
+		if ref, ok := value.(string); ok && keyword == "$ref" {
+			value = strings.Replace(ref, "#/definitions/", "#/$defs/", 1)
+		}
+		moved[keyword] = value
+	}
+	if definitions, ok := moved["definitions"].(map[string]interface{}); ok {
+		defs, _ := moved["$defs"].(map[string]interface{})
+		if defs == nil {
+			defs = map[string]interface{}{}
+		}
+		for key, definition := range definitions {
+			defs[key] = definition
+		}
+		moved["$defs"] = defs
+		delete(moved, "definitions")
+	}
+	return moved
+}
+
+// refusingTransport fails every request, so that refs to schemas outside
+// the corpus fail alike with or without network.
+type refusingTransport struct{}
+
+func (refusingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("%s is outside the corpus", r.URL)
+}
+
+// compileCorpus compiles every schema, as is or with definitionsToDefs,
+// and validates each once so that qri registers those with an absolute id,
+// which lets refs between corpus schemas resolve. Schemas that do not
+// compile are left nil and reported in the format of corpusGapsPath.
+func compileCorpus(corpus []corpusSchema, moveDefinitions bool) ([]*jsonschema.Schema, []string) {
+	schemas := make([]*jsonschema.Schema, len(corpus))
+	var failures []string
+	for i, corpusSchema := range corpus {
+		data := corpusSchema.data
+		if moveDefinitions {
+			data = ojson.MustMarshal(definitionsToDefs(mustUnmarshal(string(data))))
+		}
+		schema := new(jsonschema.Schema)
+		if err := json.Unmarshal(data, schema); err != nil {
+			failures = append(failures, compileGap(corpusSchema.name, err))
+			continue
+		}
+		schema.Validate(context.Background(), nil)
+		schemas[i] = schema
+	}
+	return schemas, failures
+}
+
+// corpusGapsPath lists the errors qri is known to report for corpus
+// examples, one "cause: schema example: path: message" per line, where
+// the cause is the keyword behind the error or, for errors that only come
+// from refs into "definitions", definitions; and the schemas qri does not
+// unmarshal, as "compile: schema: error" or, if it only fails with
+// definitions moved, "compile with $defs: schema: error". Lines starting with "#" are
+// comments.
+const corpusGapsPath = "testdata/corpus_gaps.txt"
+
+const corpusGapsHeader = `# Errors qri-io/jsonschema v0.2.1 reports for valid examples of the corpus.
+# Generated by go test -run TestCorpusExamples -update-corpus-gaps.
+#
+# "definitions" errors share one root cause: qri resolves refs into "$defs"
+# only, so every ref into "definitions", the draft-07 and earlier spelling,
+# fails twice, as an unresolved ref and as a nil schema, and so does
+# whatever holds it, such as oneOf or items. They are told apart by
+# validating again with definitions moved to "$defs": errors that go away
+# are definitions errors. The errors left are listed by keyword.
+#
+# "compile" lines are schemas qri does not unmarshal at all, whose examples
+# are not validated, and, "with $defs", schemas it only fails to unmarshal
+# once their definitions are moved, as unmarshaling "definitions", an
+# unknown keyword to qri, is skipped. Errors of their examples cannot be
+# told apart and are listed by keyword. The menuinst ones share a second
+# root cause with the menuinst "format" line: its patterns use lookahead,
+# which Go's regexp lacks.
+`
+
+// readCorpusGaps returns the lines of corpusGapsPath, comments aside.
+func readCorpusGaps() (map[string]bool, error) {
+	data, err := os.ReadFile(corpusGapsPath)
+	if err != nil {
+		return nil, err
+	}
+	known := map[string]bool{}
+	for _, gap := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		if gap != "" && !strings.HasPrefix(gap, "#") {
+			known[gap] = true
+		}
+	}
+	return known, nil
+}
+
+var updateCorpusGaps = flag.Bool("update-corpus-gaps", false, "record the errors of corpus examples into "+corpusGapsPath)
+
+// corpusFailures validates every example against its schema, as is and
+// with definitions moved to "$defs", and returns each error in the format
+// of corpusGapsPath, sorted. Refs to schemas outside the corpus fail.
+func corpusFailures(corpus []corpusSchema) ([]string, error) {
+	transport := http.DefaultTransport
+	http.DefaultTransport = refusingTransport{}
+	defer func() { http.DefaultTransport = transport }()
+	jsonschema.ResetSchemaRegistry()
+	defer jsonschema.ResetSchemaRegistry()
+	schemas, failures := compileCorpus(corpus, false)
+	jsonschema.ResetSchemaRegistry()
+	movedSchemas, movedFailures := compileCorpus(corpus, true)
+	compileFailures := map[string]bool{}
+	for _, failure := range failures {
+		compileFailures[failure] = true
+	}
+	for _, movedFailure := range movedFailures {
+		if !compileFailures[movedFailure] {
+			failures = append(failures, strings.Replace(movedFailure, ": ", " with $defs: ", 1))
+		}
+	}
+	for i, corpusSchema := range corpus {
+		if schemas[i] == nil {
+			continue
+		}
+		for _, example := range corpusSchema.examples {
+			errs, err := schemas[i].ValidateBytes(context.Background(), example.data)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", corpusSchema.name, example.name, err)
+			}
+			if len(errs) == 0 {
+				continue
+			}
+			movedErrs := errs
+			if movedSchemas[i] != nil {
+				movedErrs, err = movedSchemas[i].ValidateBytes(context.Background(), example.data)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", corpusSchema.name, example.name, err)
+				}
+			}
+			left := map[string]bool{}
+			for _, keyError := range movedErrs {
+				left[keyError.PropertyPath+": "+keyError.Message] = true
+				failures = append(failures, fmt.Sprintf("%s: %s %s: %s: %s",
+					keywordOfError(keyError), corpusSchema.name, example.name, keyError.PropertyPath, keyError.Message))
+			}
+			for _, keyError := range errs {
+				if !left[keyError.PropertyPath+": "+keyError.Message] {
+					failures = append(failures, fmt.Sprintf("definitions: %s %s: %s: %s",
+						corpusSchema.name, example.name, keyError.PropertyPath, keyError.Message))
+				}
+			}
+		}
+	}
+	sort.Strings(failures)
+	return failures, nil
+}
+
+// TestCorpusExamples validates every example against its schema. Examples
+// are valid by definition, so each error is a gap in qri. Known gaps are
+// listed in corpusGapsPath, and only new ones fail, as do listed gaps that
+// are gone, so that the list stays exact.
+func TestCorpusExamples(t *testing.T) {
+	corpus, err := loadCorpus()
+	require.NoError(t, err)
+	failures, err := corpusFailures(corpus)
+	require.NoError(t, err)
+	if *updateCorpusGaps {
+		data := corpusGapsHeader + strings.Join(failures, "\n")
+		if len(failures) > 0 {
+			data += "\n"
+		}
+		require.NoError(t, os.WriteFile(corpusGapsPath, []byte(data), 0644))
+		return
+	}
+	known, err := readCorpusGaps()
+	require.NoError(t, err)
+	var added []string
+	byCause := map[string]int{}
+	for _, failure := range failures {
+		byCause[strings.SplitN(failure, ": ", 2)[0]]++
+		if known[failure] {
+			delete(known, failure)
+		} else {
+			added = append(added, failure)
+		}
+	}
+	causes := make([]string, 0, len(byCause))
+	for cause := range byCause {
+		causes = append(causes, cause)
+	}
+	sort.Strings(causes)
+	for _, cause := range causes {
+		t.Logf("%s: %d errors", cause, byCause[cause])
+	}
+	require.Empty(t, added, "valid examples rejected beyond %s", corpusGapsPath)
+	require.Empty(t, known, "gaps in %s no longer seen, run go test -run TestCorpusExamples -update-corpus-gaps",
+		corpusGapsPath)
+}
+
+func TestKeywordOfError(t *testing.T) {
+	for message, keyword := range map[string]string{
+		"type should be string, got integer":                    "type",
+		`should be one of ["one", "two"]`:                       "enum",
+		`must equal "hello"`:                                    "const",
+		`"field" value is required`:                             "required",
+		"additional properties are not allowed":                 "additionalProperties",
+		"must be greater than or equal to 1":                    "minimum",
+		"must be a multiple of 0.1":                             "multipleOf",
+		"0 must be greater than 0":                              "exclusiveMinimum",
+		"11 must be less than 10":                               "exclusiveMaximum",
+		"must be less than or equal to 10":                      "maximum",
+		"did not match any of the specified OneOf schemas":      "oneOf",
+		"matched more than one specified OneOf schemas":         "oneOf",
+		"did Not match any specified AnyOf schemas":             "anyOf",
+		"failed to resolve schema for ref #/definitions/person": "$ref",
+		"schema is nil":                                         "$ref",
+		"invalid email: not an email":                           "format",
+		"something unheard of":                                  "other",
+	} {
+		require.Equal(t, keyword, keywordOfError(jsonschema.KeyError{Message: message}), message)
+	}
+}
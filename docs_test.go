@@ -0,0 +1,167 @@
+package ojsonschema_tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type markdownInstance struct {
+	name  string
+	data  string
+	valid bool
+}
+
+type markdownExample struct {
+	name      string
+	spec      []string
+	schema    string
+	instances []markdownInstance
+}
+
+// extractMarkdownExamples collects fenced code blocks tagged jsonschema,
+// each followed by the blocks tagged "json valid" or "json invalid" that
+// are validated against it. Other blocks are ignored. Names are file:line
+// of the opening fence. A "<!-- spec: ... -->" comment gives the next
+// jsonschema block its spec references, separated by semicolons.
+func extractMarkdownExamples(name, markdown string) ([]markdownExample, error) {
+	var examples []markdownExample
+	var spec []string
+	var tag, block string
+	var start int
+	inBlock := false
+	scanner := bufio.NewScanner(strings.NewReader(markdown))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		fence := strings.TrimSpace(text)
+		if !inBlock {
+			if strings.HasPrefix(fence, "<!-- spec:") && strings.HasSuffix(fence, "-->") {
+				spec = nil
+				for _, requirement := range strings.Split(strings.TrimSuffix(strings.TrimPrefix(fence, "<!-- spec:"), "-->"), ";") {
+					spec = append(spec, strings.TrimSpace(requirement))
+				}
+			}
+			if strings.HasPrefix(fence, "```") {
+				inBlock, tag, block, start = true, strings.TrimSpace(strings.TrimPrefix(fence, "```")), "", line
+			}
+			continue
+		}
+		if fence != "```" {
+			block += text + "\n"
+			continue
+		}
+		inBlock = false
+		location := fmt.Sprintf("%s:%d", name, start)
+		tag = strings.Join(strings.Fields(tag), " ")
+		switch tag {
+		case "jsonschema":
+			examples = append(examples, markdownExample{name: location, spec: spec, schema: block})
+			spec = nil
+		case "json valid", "json invalid":
+			if len(examples) == 0 {
+				return nil, fmt.Errorf("%s: %q block before any jsonschema block", location, tag)
+			}
+			example := &examples[len(examples)-1]
+			example.instances = append(example.instances, markdownInstance{
+				name:  location,
+				data:  block,
+				valid: tag == "json valid",
+			})
+		}
+	}
+	if inBlock {
+		return nil, fmt.Errorf("%s:%d: unterminated code block", name, start)
+	}
+	return examples, scanner.Err()
+}
+
+func markdownFiles() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join("docs", "*.md"))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"README.md"}, files...), nil
+}
+
+// markdownFileExamples returns the examples of every Markdown file.
+func markdownFileExamples() ([]markdownExample, error) {
+	files, err := markdownFiles()
+	if err != nil {
+		return nil, err
+	}
+	var examples []markdownExample
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileExamples, err := extractMarkdownExamples(file, string(data))
+		if err != nil {
+			return nil, err
+		}
+		examples = append(examples, fileExamples...)
+	}
+	return examples, nil
+}
+
+func TestMarkdownExamples(t *testing.T) {
+	examples, err := markdownFileExamples()
+	require.NoError(t, err)
+	for _, example := range examples {
+		t.Run(example.name, func(t *testing.T) {
+			require.NotEmpty(t, example.instances, "schema without instances")
+			schema := compileSchema(t, json.RawMessage(example.schema))
+			for _, instance := range example.instances {
+				t.Run(instance.name, func(t *testing.T) {
+					var actual interface{}
+					require.NoError(t, json.Unmarshal([]byte(instance.data), &actual))
+					state := schema.Validate(context.Background(), actual)
+					require.Equal(t, instance.valid, len(*state.Errs) == 0, "%v", *state.Errs)
+				})
+			}
+		})
+	}
+}
+
+func TestExtractMarkdownExamples(t *testing.T) {
+	markdown := strings.Join([]string{
+		"# Title",
+		"<!-- spec: validation §6.1.1 type; validation §6.1.2 enum -->",
+		"```jsonschema",
+		`{"type": "string"}`,
+		"```",
+		"```json valid",
+		`"hello"`,
+		"```",
+		"```go",
+		"fmt.Println()",
+		"```",
+		"```json  invalid",
+		`42`,
+		"```",
+	}, "\n")
+	examples, err := extractMarkdownExamples("doc.md", markdown)
+	require.NoError(t, err)
+	require.Equal(t, []markdownExample{
+		{
+			name:   "doc.md:3",
+			spec:   []string{"validation §6.1.1 type", "validation §6.1.2 enum"},
+			schema: "{\"type\": \"string\"}\n",
+			instances: []markdownInstance{
+				{name: "doc.md:6", data: "\"hello\"\n", valid: true},
+				{name: "doc.md:12", data: "42\n", valid: false},
+			},
+		},
+	}, examples)
+
+	_, err = extractMarkdownExamples("doc.md", "```json valid\n1\n```\n")
+	require.EqualError(t, err, `doc.md:1: "json valid" block before any jsonschema block`)
+	_, err = extractMarkdownExamples("doc.md", "```jsonschema\n{}\n")
+	require.EqualError(t, err, "doc.md:1: unterminated code block")
+}
@@ -0,0 +1,251 @@
+package ojsonschema_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+type duplicateKeyFrame struct {
+	path      string
+	keys      map[string]bool
+	key       string
+	expectKey bool
+	index     int
+}
+
+// findDuplicateKeys scans raw JSON and returns the JSON Pointer of every
+// object member whose key repeats an earlier one in the same object.
+// Keys are compared after unescaping, so "a" and "\u0061" are duplicates.
+// Pointers follow RFC 6901, so "/" is the member with the empty key.
+func findDuplicateKeys(data []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	duplicates := []string{}
+	var stack []*duplicateKeyFrame
+	done := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return nil, fmt.Errorf("unexpected data after top-level value")
+		}
+		path := ""
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+				stack = stack[:len(stack)-1]
+				done = len(stack) == 0
+				continue
+			}
+			if top.keys != nil {
+				if top.expectKey {
+					key := token.(string)
+					if top.keys[key] {
+						duplicates = append(duplicates, childPath(top.path, key))
+					}
+					top.keys[key] = true
+					top.key = key
+					top.expectKey = false
+					continue
+				}
+				path = childPath(top.path, top.key)
+				top.expectKey = true
+			} else {
+				path = childPath(top.path, fmt.Sprint(top.index))
+				top.index++
+			}
+		}
+		switch token {
+		case json.Delim('{'):
+			stack = append(stack, &duplicateKeyFrame{path: path, keys: map[string]bool{}, expectKey: true})
+		case json.Delim('['):
+			stack = append(stack, &duplicateKeyFrame{path: path})
+		default:
+			done = len(stack) == 0
+		}
+	}
+	if !done {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return duplicates, nil
+}
+
+// duplicateKeyErrors reports the duplicate keys of raw JSON as KeyErrors,
+// one per repeated member, with the key as the invalid value.
+func duplicateKeyErrors(data []byte) ([]jsonschema.KeyError, error) {
+	duplicates, err := findDuplicateKeys(data)
+	if err != nil {
+		return nil, err
+	}
+	errs := []jsonschema.KeyError{}
+	for _, pointer := range duplicates {
+		key := pointer[strings.LastIndex(pointer, "/")+1:]
+		key = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+		errs = append(errs, jsonschema.KeyError{
+			PropertyPath: pointer,
+			InvalidValue: key,
+			Message:      fmt.Sprintf("duplicate key %q", key),
+		})
+	}
+	return errs, nil
+}
+
+// validateBytesStrict validates raw JSON like ValidateBytes, but reports
+// duplicate keys as KeyErrors ahead of the schema errors, since
+// encoding/json would otherwise silently keep the last value.
+func validateBytesStrict(ctx context.Context, schema *jsonschema.Schema, data []byte) ([]jsonschema.KeyError, error) {
+	errs, err := duplicateKeyErrors(data)
+	if err != nil {
+		return nil, err
+	}
+	schemaErrs, err := schema.ValidateBytes(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(errs, schemaErrs...), nil
+}
+
+// unmarshalSchemaStrict unmarshals a schema, refusing duplicate keys.
+func unmarshalSchemaStrict(data []byte, schema *jsonschema.Schema) error {
+	duplicates, err := findDuplicateKeys(data)
+	if err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate keys in schema: %s", strings.Join(duplicates, ", "))
+	}
+	return json.Unmarshal(data, schema)
+}
+
+var duplicateKeyCases = []struct {
+	name     string
+	data     string
+	expected []string
+}{
+	{
+		name:     "no duplicates",
+		data:     `{"field": 1, "Field": 2, "other": {"field": 3}}`,
+		expected: []string{},
+	},
+	{
+		name:     "scalars",
+		data:     `"field"`,
+		expected: []string{},
+	},
+	{
+		name:     "top level",
+		data:     `{"field": 1, "field": "hello"}`,
+		expected: []string{"/field"},
+	},
+	{
+		name:     "repeated three times",
+		data:     `{"field": 1, "field": 2, "field": 3}`,
+		expected: []string{"/field", "/field"},
+	},
+	{
+		name:     "nested object",
+		data:     `{"outer": {"field": 1, "field": 2}, "field": 3}`,
+		expected: []string{"/outer/field"},
+	},
+	{
+		name:     "object in array",
+		data:     `[{"field": 1}, [], {"field": 1, "field": 2}]`,
+		expected: []string{"/2/field"},
+	},
+	{
+		name:     "duplicated key of a container value",
+		data:     `{"outer": {"field": 1}, "outer": {"field": 1, "field": 2}}`,
+		expected: []string{"/outer", "/outer/field"},
+	},
+	{
+		name:     "unicode escape",
+		data:     `{"field": 1, "fi\u0065ld": 2}`,
+		expected: []string{"/field"},
+	},
+	{
+		name:     "escaped slash needs pointer escaping",
+		data:     `{"a/b": 1, "a\/b": 2, "~": 3, "~": 4}`,
+		expected: []string{"/a~1b", "/~0"},
+	},
+	{
+		name:     "empty key",
+		data:     `{"": 1, "": 2}`,
+		expected: []string{"/"},
+	},
+	{
+		name:     "key nested under an empty key",
+		data:     `{"": {"x": 1, "x": 2}, "x": 3}`,
+		expected: []string{"//x"},
+	},
+	{
+		name:     "empty key nested under an empty key",
+		data:     `{"": {"": 1, "": 2}}`,
+		expected: []string{"//"},
+	},
+	{
+		name:     "empty key in array under an empty key",
+		data:     `{"": [{"": 1, "": 2}]}`,
+		expected: []string{"//0/"},
+	},
+}
+
+func TestFindDuplicateKeys(t *testing.T) {
+	for _, duplicateKeyCase := range duplicateKeyCases {
+		t.Run(duplicateKeyCase.name, func(t *testing.T) {
+			duplicates, err := findDuplicateKeys([]byte(duplicateKeyCase.data))
+			require.NoError(t, err)
+			require.Equal(t, duplicateKeyCase.expected, duplicates)
+		})
+	}
+}
+
+func TestFindDuplicateKeysInvalidJSON(t *testing.T) {
+	for _, data := range []string{`{"field": 1,}`, `{"field" 1}`, `[1, 2`, `{} {}`, ``} {
+		_, err := findDuplicateKeys([]byte(data))
+		require.Error(t, err, data)
+	}
+}
+
+func TestDuplicateKeysInInstances(t *testing.T) {
+	schema := new(jsonschema.Schema)
+	require.NoError(t, json.Unmarshal(ojson.MustMarshal(ojsonschema.Object{
+		AdditionalProperties: false,
+		Properties: ojson.Object{
+			"field": ojsonschema.String{},
+		},
+		Required: ojson.Array{"field"},
+	}), schema))
+	data := []byte(`{"field": 1, "field": "hello"}`)
+
+	errs, err := schema.ValidateBytes(context.Background(), data)
+	require.NoError(t, err)
+	require.Equal(t, []jsonschema.KeyError{}, errs, "encoding/json keeps the last value")
+
+	errs, err = validateBytesStrict(context.Background(), schema, data)
+	require.NoError(t, err)
+	require.Equal(t, []jsonschema.KeyError{
+		{PropertyPath: "/field", InvalidValue: "field", Message: `duplicate key "field"`},
+	}, errs)
+}
+
+func TestDuplicateKeysInSchemas(t *testing.T) {
+	data := []byte(`{"type": "object", "properties": {"field": {"type": "integer", "type": "string"}}}`)
+	require.NoError(t, json.Unmarshal(data, new(jsonschema.Schema)))
+	err := unmarshalSchemaStrict(data, new(jsonschema.Schema))
+	require.EqualError(t, err, "duplicate keys in schema: /properties/field/type")
+	require.NoError(t, unmarshalSchemaStrict(ojson.MustMarshal(ojsonschema.String{}), new(jsonschema.Schema)))
+}
@@ -0,0 +1,48 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"github.com/gogolibs/ojson"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// emptyValues are validated against every schema case, so that behavior on
+// them is pinned down without a hand-written validation case for each.
+var emptyValues = []struct {
+	name  string
+	value ojson.Anything
+}{
+	{name: `""`, value: ""},
+	{name: `{}`, value: ojson.Object{}},
+	{name: `[]`, value: ojson.Array{}},
+	{name: `0`, value: 0},
+	{name: `false`, value: false},
+	{name: `null`, value: nil},
+}
+
+func TestEmptyValues(t *testing.T) {
+	names := map[string]bool{}
+	for _, emptyValue := range emptyValues {
+		names[emptyValue.name] = true
+	}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			t.Run(schemaSuite.name+"/"+schemaCase.name, func(t *testing.T) {
+				require.NotNil(t, schemaCase.emptyAccepted, "emptyAccepted is not recorded")
+				accepted := map[string]bool{}
+				for _, name := range schemaCase.emptyAccepted {
+					require.True(t, names[name], "unknown empty value %s", name)
+					accepted[name] = true
+				}
+				schema := compileSchema(t, schemaCase.schema)
+				for _, emptyValue := range emptyValues {
+					t.Run(emptyValue.name, func(t *testing.T) {
+						state := schema.Validate(context.Background(), emptyValue.value)
+						require.Equal(t, accepted[emptyValue.name], len(*state.Errs) == 0, "%v", *state.Errs)
+					})
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package ojsonschema_tests
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+var updateExpectations = flag.Bool("update-expectations", false,
+	"rewrite the expected KeyErrors of schema suites in Go source with actual results")
+
+// goLiteral renders a value as found in KeyErrors as Go source that
+// evaluates to an equal interface{} value.
+func goLiteral(value interface{}) (string, error) {
+	if value == nil {
+		return "nil", nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Type().PkgPath() != "" {
+		return "", fmt.Errorf("cannot render %T as a literal", value)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.String:
+		return goStringLiteral(v.String()), nil
+	case reflect.Int:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprintf("%s(%d)", v.Type(), v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return fmt.Sprintf("%s(%d)", v.Type(), v.Uint()), nil
+	case reflect.Float64, reflect.Float32:
+		f := strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+		if strings.ContainsAny(f, "IN") {
+			return "", fmt.Errorf("cannot render %v as a literal", value)
+		}
+		if !strings.ContainsAny(f, ".e") {
+			f += ".0"
+		}
+		if v.Kind() == reflect.Float32 {
+			return "float32(" + f + ")", nil
+		}
+		return f, nil
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("cannot render %T as a literal", value)
+		}
+		members := []string{}
+		for _, key := range sortedKeys(object) {
+			member, err := goLiteral(object[key])
+			if err != nil {
+				return "", err
+			}
+			members = append(members, goStringLiteral(key)+": "+member)
+		}
+		return "map[string]interface{}{" + strings.Join(members, ", ") + "}", nil
+	case reflect.Slice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("cannot render %T as a literal", value)
+		}
+		items := []string{}
+		for _, item := range array {
+			literal, err := goLiteral(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, literal)
+		}
+		return "[]interface{}{" + strings.Join(items, ", ") + "}", nil
+	}
+	return "", fmt.Errorf("cannot render %T as a literal", value)
+}
+
+// goStringLiteral prefers a raw string for printable strings with double
+// quotes, the way messages are written in the suites.
+func goStringLiteral(s string) string {
+	raw := strings.Contains(s, `"`) && !strings.Contains(s, "`") && utf8.ValidString(s)
+	for _, r := range s {
+		raw = raw && unicode.IsPrint(r)
+	}
+	if raw {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
+
+func keyErrorsLiteral(errs []jsonschema.KeyError) (string, error) {
+	if len(errs) == 0 {
+		return "[]jsonschema.KeyError{}", nil
+	}
+	buffer := bytes.NewBufferString("[]jsonschema.KeyError{\n")
+	for _, err := range errs {
+		invalidValue, literalErr := goLiteral(err.InvalidValue)
+		if literalErr != nil {
+			return "", literalErr
+		}
+		fmt.Fprintf(buffer, "{\nPropertyPath: %s,\nInvalidValue: %s,\nMessage: %s,\n},\n",
+			strconv.Quote(err.PropertyPath), invalidValue, goStringLiteral(err.Message))
+	}
+	buffer.WriteString("}")
+	return buffer.String(), nil
+}
+
+func stringField(literal *ast.CompositeLit, field string) (string, bool) {
+	for _, element := range literal.Elts {
+		keyValue, ok := element.(*ast.KeyValueExpr)
+		if !ok || !isIdent(keyValue.Key, field) {
+			continue
+		}
+		basic, ok := keyValue.Value.(*ast.BasicLit)
+		if !ok || basic.Kind != token.STRING {
+			return "", false
+		}
+		value, err := strconv.Unquote(basic.Value)
+		return value, err == nil
+	}
+	return "", false
+}
+
+func field(literal *ast.CompositeLit, field string) ast.Expr {
+	for _, element := range literal.Elts {
+		if keyValue, ok := element.(*ast.KeyValueExpr); ok && isIdent(keyValue.Key, field) {
+			return keyValue.Value
+		}
+	}
+	return nil
+}
+
+func isIdent(expr ast.Expr, name string) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == name
+}
+
+// topLevelLiterals returns the composite literal assigned to each
+// package-level var of a file.
+func topLevelLiterals(file *ast.File) map[string]*ast.CompositeLit {
+	literals := map[string]*ast.CompositeLit{}
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i < len(valueSpec.Values) {
+					if literal, ok := valueSpec.Values[i].(*ast.CompositeLit); ok {
+						literals[name.Name] = literal
+					}
+				}
+			}
+		}
+	}
+	return literals
+}
+
+// rewriteExpectations replaces the expected field of the validation cases
+// in updates, keyed by "<var>/<schema case>/<validation case>", in the
+// source of one Go file. Cases are found by their literal names; the keys
+// it rewrote are returned alongside the formatted source.
+func rewriteExpectations(src []byte, updates map[string][]jsonschema.KeyError) ([]byte, []string, error) {
+	fileSet := token.NewFileSet()
+	file, err := parser.ParseFile(fileSet, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, err
+	}
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	var replacements []replacement
+	var applied []string
+	for name, literal := range topLevelLiterals(file) {
+		for _, element := range literal.Elts {
+			schemaCase, ok := element.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			schemaName, ok := stringField(schemaCase, "name")
+			validationCases, _ := field(schemaCase, "validationCases").(*ast.CompositeLit)
+			if !ok || validationCases == nil {
+				continue
+			}
+			for _, element := range validationCases.Elts {
+				validationCase, ok := element.(*ast.CompositeLit)
+				if !ok {
+					continue
+				}
+				validationName, _ := stringField(validationCase, "name")
+				key := name + "/" + schemaName + "/" + validationName
+				errs, ok := updates[key]
+				expected := field(validationCase, "expected")
+				if !ok || expected == nil {
+					continue
+				}
+				text, err := keyErrorsLiteral(errs)
+				if err != nil {
+					return nil, nil, fmt.Errorf("%s: %w", key, err)
+				}
+				replacements = append(replacements, replacement{
+					start: fileSet.Position(expected.Pos()).Offset,
+					end:   fileSet.Position(expected.End()).Offset,
+					text:  text,
+				})
+				applied = append(applied, key)
+			}
+		}
+	}
+	if len(replacements) == 0 {
+		return src, nil, nil
+	}
+	sort.Slice(replacements, func(i, j int) bool { return replacements[i].start > replacements[j].start })
+	rewritten := append([]byte(nil), src...)
+	for _, r := range replacements {
+		rewritten = append(rewritten[:r.start:r.start], append([]byte(r.text), rewritten[r.end:]...)...)
+	}
+	formatted, err := format.Source(rewritten)
+	sort.Strings(applied)
+	return formatted, applied, err
+}
+
+// suiteVars maps each schema suite name to the var holding its cases, as
+// listed in the schemaSuites literal.
+func suiteVars(files map[string][]byte) (map[string]string, error) {
+	for _, src := range files {
+		file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+		if err != nil {
+			return nil, err
+		}
+		literal, ok := topLevelLiterals(file)["schemaSuites"]
+		if !ok {
+			continue
+		}
+		vars := map[string]string{}
+		for _, element := range literal.Elts {
+			suite, ok := element.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			name, ok := stringField(suite, "name")
+			if ident, isIdent := field(suite, "schemaCases").(*ast.Ident); ok && isIdent {
+				vars[name] = ident.Name
+			}
+		}
+		return vars, nil
+	}
+	return nil, fmt.Errorf("schemaSuites not found")
+}
+
+func TestUpdateExpectations(t *testing.T) {
+	if !*updateExpectations {
+		t.Skip("run with -update-expectations to rewrite expected KeyErrors")
+	}
+	names, err := filepath.Glob("*_test.go")
+	require.NoError(t, err)
+	files := map[string][]byte{}
+	for _, name := range names {
+		files[name], err = os.ReadFile(name)
+		require.NoError(t, err)
+	}
+	vars, err := suiteVars(files)
+	require.NoError(t, err)
+
+	updates := map[string][]jsonschema.KeyError{}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			schema := compileSchema(t, schemaCase.schema)
+			for _, validationCase := range schemaCase.validationCases {
+				state := schema.Validate(context.Background(), validationCase.actual)
+				if !reflect.DeepEqual(validationCase.expected, *state.Errs) {
+					key := vars[schemaSuite.name] + "/" + schemaCase.name + "/" + validationCase.name
+					updates[key] = *state.Errs
+				}
+			}
+		}
+	}
+	for _, name := range names {
+		rewritten, applied, err := rewriteExpectations(files[name], updates)
+		require.NoError(t, err, name)
+		for _, key := range applied {
+			t.Logf("%s: updated %s", name, key)
+			delete(updates, key)
+		}
+		if len(applied) > 0 {
+			require.NoError(t, os.WriteFile(name, rewritten, 0644))
+		}
+	}
+	require.Empty(t, updates, "cases without literal names to update by hand")
+}
+
+func TestRewriteExpectations(t *testing.T) {
+	src := []byte(`package example
+
+var exampleCases = []schemaCase{
+	{
+		name: "string",
+		validationCases: []validationCase{
+			{
+				name:     "integer",
+				actual:   42,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "untouched",
+				actual:   "hello",
+				expected: []jsonschema.KeyError{},
+			},
+		},
+	},
+}
+`)
+	rewritten, applied, err := rewriteExpectations(src, map[string][]jsonschema.KeyError{
+		"exampleCases/string/integer": {
+			{PropertyPath: "/", InvalidValue: 42, Message: "type should be string, got integer"},
+			{PropertyPath: "/", InvalidValue: map[string]interface{}{"b": 1.5, "a": []interface{}{nil}}, Message: `must equal "x"`},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"exampleCases/string/integer"}, applied)
+	require.Equal(t, `package example
+
+var exampleCases = []schemaCase{
+	{
+		name: "string",
+		validationCases: []validationCase{
+			{
+				name:   "integer",
+				actual: 42,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: 42,
+						Message:      "type should be string, got integer",
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"a": []interface{}{nil}, "b": 1.5},
+						Message:      `+"`must equal \"x\"`"+`,
+					},
+				},
+			},
+			{
+				name:     "untouched",
+				actual:   "hello",
+				expected: []jsonschema.KeyError{},
+			},
+		},
+	},
+}
+`, string(rewritten))
+}
+
+func TestGoLiteral(t *testing.T) {
+	for value, expected := range map[interface{}]string{
+		nil:          "nil",
+		true:         "true",
+		"a\x00":      `"a\x00"`,
+		42:           "42",
+		int64(42):    "int64(42)",
+		uint64(42):   "uint64(42)",
+		42.0:         "42.0",
+		1e300:        "1e+300",
+		float32(1.5): "float32(1.5)",
+	} {
+		literal, err := goLiteral(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, literal)
+	}
+	_, err := goLiteral(goStatusActive)
+	require.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package ojsonschema_tests
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/gogolibs/ojson"
+	"github.com/stretchr/testify/require"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var exportTestSuiteDir = flag.String("export-test-suite", "",
+	"write every schema suite as JSON-Schema-Test-Suite files into this directory")
+
+// testSuiteGroup and testSuiteTest follow the file format of
+// https://github.com/json-schema-org/JSON-Schema-Test-Suite.
+type testSuiteGroup struct {
+	Description string          `json:"description"`
+	Schema      json.RawMessage `json:"schema"`
+	Tests       []testSuiteTest `json:"tests"`
+}
+
+type testSuiteTest struct {
+	Description string          `json:"description"`
+	Data        json.RawMessage `json:"data"`
+	Valid       bool            `json:"valid"`
+}
+
+// encodedDiffers reports whether the JSON encoding of an in-memory
+// instance, decoded again, is a different JSON value, as for a
+// json.Marshaler struct, a string holding invalid UTF-8 or a NaN. The
+// expectations of such a case hold for the Go value only.
+func encodedDiffers(value interface{}) bool {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return true
+	}
+	decoded, err := decodeExact(data)
+	return err != nil || !exactEqual(value, decoded)
+}
+
+// exportTestSuite writes one file per schema suite into dir. Validity is
+// taken from the expected errors, and cases whose encoded instance differs
+// from the in-memory Go value are left out, as other validators are only
+// given the encoding.
+func exportTestSuite(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, schemaSuite := range schemaSuites {
+		groups := []testSuiteGroup{}
+		for _, schemaCase := range schemaSuite.schemaCases {
+			group := testSuiteGroup{
+				Description: schemaCase.name,
+				Schema:      ojson.MustMarshal(schemaCase.schema),
+				Tests:       []testSuiteTest{},
+			}
+			for _, validationCase := range schemaCase.validationCases {
+				if encodedDiffers(validationCase.actual) {
+					continue
+				}
+				group.Tests = append(group.Tests, testSuiteTest{
+					Description: validationCase.name,
+					Data:        ojson.MustMarshal(validationCase.actual),
+					Valid:       len(validationCase.expected) == 0,
+				})
+			}
+			groups = append(groups, group)
+		}
+		data, err := json.MarshalIndent(groups, "", "    ")
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(dir, strings.ReplaceAll(schemaSuite.name, " ", "-")+".json")
+		if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestExportTestSuite reads the exported files back and checks them against
+// the cases they were written from. qri is not asked: it decodes numbers
+// into float64, so it rejects an exported uint64 max that is an integer.
+func TestExportTestSuite(t *testing.T) {
+	if *exportTestSuiteDir != "" {
+		require.NoError(t, exportTestSuite(*exportTestSuiteDir))
+	}
+	dir := t.TempDir()
+	require.NoError(t, exportTestSuite(dir))
+	for _, schemaSuite := range schemaSuites {
+		t.Run(schemaSuite.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join(dir, strings.ReplaceAll(schemaSuite.name, " ", "-")+".json"))
+			require.NoError(t, err)
+			var groups []testSuiteGroup
+			require.NoError(t, json.Unmarshal(data, &groups))
+			require.Len(t, groups, len(schemaSuite.schemaCases))
+			for i, group := range groups {
+				schemaCase := schemaSuite.schemaCases[i]
+				require.Equal(t, schemaCase.name, group.Description)
+				require.JSONEq(t, string(ojson.MustMarshal(schemaCase.schema)), string(group.Schema))
+				var expected []validationCase
+				for _, validationCase := range schemaCase.validationCases {
+					if !encodedDiffers(validationCase.actual) {
+						expected = append(expected, validationCase)
+					}
+				}
+				require.Len(t, group.Tests, len(expected), group.Description)
+				for j, test := range group.Tests {
+					require.Equal(t, expected[j].name, test.Description, group.Description)
+					require.Equal(t, len(expected[j].expected) == 0, test.Valid, "%s/%s", group.Description, test.Description)
+					instance, err := decodeExact(test.Data)
+					require.NoError(t, err)
+					require.True(t, exactEqual(expected[j].actual, instance), "%s/%s", group.Description, test.Description)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodedDiffers(t *testing.T) {
+	for _, value := range []interface{}{nil, "hello", 42, 1.5, json.Number("1e2"), ojson.Object{"a": ojson.Array{1, true}}} {
+		require.False(t, encodedDiffers(value), "%#v", value)
+	}
+	for _, value := range []interface{}{goPoint{X: 1, Y: 2}, goStatusActive, "\xff", math.NaN(), []byte("hello")} {
+		require.True(t, encodedDiffers(value), "%#v", value)
+	}
+}
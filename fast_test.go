@@ -0,0 +1,352 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strconv"
+	"testing"
+	"unicode/utf8"
+)
+
+// fastKeywords are the keywords the boolean-only path evaluates itself.
+// Annotations are known but never fail.
+var fastKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"required": true, "properties": true, "additionalProperties": true,
+	"items": true, "minItems": true, "maxItems": true,
+	"minLength": true, "maxLength": true, "minimum": true, "maximum": true,
+	"$schema": true, "$comment": true, "title": true, "description": true, "default": true, "examples": true,
+}
+
+// fastSchema answers whether an instance is valid, stopping at the first
+// failing keyword without building KeyErrors or formatting messages.
+// Schemas using other keywords, and instances that are not plain decoded
+// JSON, are left to qri. Its quirks are copied so that the answer is qri's.
+type fastSchema struct {
+	schema   interface{}
+	fallback *jsonschema.Schema
+	fast     bool
+}
+
+func compileFastSchema(schema ojson.Anything) (*fastSchema, error) {
+	data := ojson.MustMarshal(schema)
+	compiled := &fastSchema{fallback: new(jsonschema.Schema)}
+	if err := json.Unmarshal(data, compiled.fallback); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &compiled.schema); err != nil {
+		return nil, err
+	}
+	compiled.fast = fastSupported(compiled.schema)
+	return compiled, nil
+}
+
+func (s *fastSchema) Valid(ctx context.Context, instance interface{}) bool {
+	if s.fast && isPlainJSON(instance) {
+		return fastValid(s.schema, instance)
+	}
+	return len(*s.fallback.Validate(ctx, instance).Errs) == 0
+}
+
+// fastSupported reports whether a decoded schema only uses fastKeywords,
+// with items in its single schema form.
+func fastSupported(schema interface{}) bool {
+	if _, ok := schema.(bool); ok {
+		return true
+	}
+	members, ok := schema.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for keyword, value := range members {
+		if !fastKeywords[keyword] {
+			return false
+		}
+		switch keyword {
+		case "properties":
+			properties, ok := value.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			for _, property := range properties {
+				if !fastSupported(property) {
+					return false
+				}
+			}
+		case "additionalProperties", "items":
+			if !fastSupported(value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isPlainJSON reports whether value is made of the types encoding/json
+// decodes into, the only ones whose handling by qri is mirrored here.
+func isPlainJSON(value interface{}) bool {
+	switch value := value.(type) {
+	case nil, bool, string:
+		return true
+	case float64:
+		return !math.IsNaN(value) && !math.IsInf(value, 0)
+	case map[string]interface{}:
+		for _, member := range value {
+			if !isPlainJSON(member) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		for _, item := range value {
+			if !isPlainJSON(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// fastType is the JSON type qri gives a decoded value: a float64 is an
+// integer only when it is whole and fits an int64, see integerSchemaCases.
+func fastType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if math.Trunc(value) == value && value >= math.MinInt64 && value < math.MaxInt64 {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "array"
+}
+
+// fastTypeMatches follows qri's DataTypeWithHint, which takes a string
+// strconv.ParseBool accepts, such as "true", "1" or "t", for a boolean.
+func fastTypeMatches(expected interface{}, instance interface{}) bool {
+	if value, ok := instance.(string); ok {
+		if _, err := strconv.ParseBool(value); err == nil {
+			return fastTypeNameMatches(expected, "string") || fastTypeNameMatches(expected, "boolean")
+		}
+	}
+	return fastTypeNameMatches(expected, fastType(instance))
+}
+
+func fastTypeNameMatches(expected interface{}, actual string) bool {
+	switch expected := expected.(type) {
+	case string:
+		return expected == actual || expected == "number" && actual == "integer"
+	case []interface{}:
+		for _, candidate := range expected {
+			if fastTypeNameMatches(candidate, actual) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fastValid(schema interface{}, instance interface{}) bool {
+	if accepts, ok := schema.(bool); ok {
+		return accepts
+	}
+	members := schema.(map[string]interface{})
+	if expected, ok := members["type"]; ok && !fastTypeMatches(expected, instance) {
+		return false
+	}
+	if enum, ok := members["enum"].([]interface{}); ok {
+		found := false
+		for _, member := range enum {
+			found = found || exactEqual(member, instance)
+		}
+		if !found {
+			return false
+		}
+	}
+	if constant, ok := members["const"]; ok && !exactEqual(constant, instance) {
+		return false
+	}
+	switch instance := instance.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(instance))
+		if minLength, ok := members["minLength"].(float64); ok && length < minLength {
+			return false
+		}
+		if maxLength, ok := members["maxLength"].(float64); ok && length > maxLength {
+			return false
+		}
+	case float64:
+		if minimum, ok := members["minimum"].(float64); ok && instance < minimum {
+			return false
+		}
+		if maximum, ok := members["maximum"].(float64); ok && instance > maximum {
+			return false
+		}
+	case map[string]interface{}:
+		if required, ok := members["required"].([]interface{}); ok {
+			for _, key := range required {
+				name, _ := key.(string)
+				if _, ok := instance[name]; !ok {
+					return false
+				}
+			}
+		}
+		properties, _ := members["properties"].(map[string]interface{})
+		additionalProperties, hasAdditionalProperties := members["additionalProperties"]
+		for key, value := range instance {
+			if property, ok := properties[key]; ok {
+				if !fastValid(property, value) {
+					return false
+				}
+			} else if hasAdditionalProperties && !fastValid(additionalProperties, value) {
+				return false
+			}
+		}
+	case []interface{}:
+		length := float64(len(instance))
+		if minItems, ok := members["minItems"].(float64); ok && length < minItems {
+			return false
+		}
+		if maxItems, ok := members["maxItems"].(float64); ok && length > maxItems {
+			return false
+		}
+		if items, ok := members["items"]; ok {
+			for _, item := range instance {
+				if !fastValid(items, item) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func TestFastSchemaAgreesWithQri(t *testing.T) {
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			t.Run(schemaSuite.name+"/"+schemaCase.name, func(t *testing.T) {
+				schema, err := compileFastSchema(schemaCase.schema)
+				require.NoError(t, err)
+				for _, validationCase := range schemaCase.validationCases {
+					valid := len(*schema.fallback.Validate(context.Background(), validationCase.actual).Errs) == 0
+					require.Equal(t, valid, schema.Valid(context.Background(), validationCase.actual), validationCase.name)
+					require.Equal(t, len(validationCase.expected) == 0, valid, validationCase.name)
+				}
+				for _, emptyValue := range emptyValues {
+					valid := len(*schema.fallback.Validate(context.Background(), emptyValue.value).Errs) == 0
+					require.Equal(t, valid, schema.Valid(context.Background(), emptyValue.value), "empty %s", emptyValue.name)
+				}
+			})
+		}
+	}
+	for _, generated := range generatePairwiseCases(keywordFragments, pairwiseInstances) {
+		schema, err := compileFastSchema(generated.schema)
+		require.NoError(t, err)
+		for _, instance := range generated.instances {
+			require.Equal(t, instance.valid, schema.Valid(context.Background(), mustUnmarshal(instance.actual)),
+				"%s: %s", generated.name, instance.actual)
+		}
+	}
+}
+
+// booleanTypeSchemas and booleanTypeInstances cover strings qri takes for
+// booleans, see fastTypeMatches.
+var booleanTypeSchemas = []string{
+	`{"type": "boolean"}`,
+	`{"type": ["boolean", "null"]}`,
+	`{"type": ["string", "boolean"]}`,
+	`{"properties": {"flag": {"type": "boolean"}}}`,
+}
+
+var booleanTypeInstances = []string{
+	`true`, `false`, `"true"`, `"1"`, `"t"`, `"F"`, `"FALSE"`, `"yes"`, `""`, `1`, `null`,
+	`{"flag": "true"}`, `{"flag": "0"}`, `{"flag": "no"}`,
+}
+
+func TestFastSchemaAgreesWithQriOnBooleans(t *testing.T) {
+	for _, schemaData := range booleanTypeSchemas {
+		schema, err := compileFastSchema(mustUnmarshal(schemaData))
+		require.NoError(t, err)
+		require.True(t, schema.fast, schemaData)
+		for _, instance := range booleanTypeInstances {
+			valid := len(*schema.fallback.Validate(context.Background(), mustUnmarshal(instance)).Errs) == 0
+			require.Equal(t, valid, schema.Valid(context.Background(), mustUnmarshal(instance)), "%s: %s", schemaData, instance)
+		}
+	}
+}
+
+func TestFastSchemaFallsBackToQri(t *testing.T) {
+	for schema, fast := range map[string]bool{
+		`{"type": "object", "properties": {"field": {"type": "string"}}, "additionalProperties": false}`: true,
+		`{"items": {"enum": [1, 2]}, "title": "annotated"}`:                                              true,
+		`{"type": "string", "pattern": "^a"}`:                                                            false,
+		`{"properties": {"field": {"anyOf": [true]}}}`:                                                   false,
+		`{"items": [{"type": "string"}]}`:                                                                false,
+	} {
+		compiled, err := compileFastSchema(mustUnmarshal(schema))
+		require.NoError(t, err)
+		require.Equal(t, fast, compiled.fast, schema)
+	}
+	require.True(t, isPlainJSON(mustUnmarshal(`{"a": [1, "b", null, true]}`)))
+	for _, value := range []interface{}{42, json.Number("1"), math.NaN(), goStatusActive, []interface{}{int64(1)}} {
+		require.False(t, isPlainJSON(value), "%#v", value)
+	}
+}
+
+// invalidHeavyTraffic is nine invalid requests for every valid one.
+var invalidHeavyTraffic = []string{
+	`{"id": "1", "status": "active", "tags": ["a"]}`,
+	`{"status": "active"}`,
+	`{"id": 1, "status": "active"}`,
+	`{"id": "1", "status": "unknown"}`,
+	`{"id": "1", "status": "active", "tags": [1]}`,
+	`{"id": "1", "status": "active", "extra": true}`,
+	`[]`,
+	`"request"`,
+	`{"id": "", "status": "active"}`,
+	`{"id": "1", "status": "inactive", "tags": ["a", "b", "c", "d"]}`,
+}
+
+func BenchmarkInvalidHeavyTraffic(b *testing.B) {
+	schema, err := compileFastSchema(ojsonschema.Object{
+		AdditionalProperties: false,
+		Properties: ojson.Object{
+			"id":     ojson.Object{"type": "string", "minLength": 1},
+			"status": ojsonschema.String{Enum: ojson.Array{"active", "inactive"}},
+			"tags":   ojson.Object{"type": "array", "items": ojsonschema.String{}, "maxItems": 3},
+		},
+		Required: ojson.Array{"id", "status"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var instances []interface{}
+	for _, instance := range invalidHeavyTraffic {
+		instances = append(instances, mustUnmarshal(instance))
+	}
+	b.Run("KeyErrors", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = len(*schema.fallback.Validate(context.Background(), instances[i%len(instances)]).Errs) == 0
+		}
+	})
+	b.Run("fail-fast", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = schema.Valid(context.Background(), instances[i%len(instances)])
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"strings"
+	"testing"
+	"text/tabwriter"
+)
+
+var memoryReport = flag.Bool("memory-report", false, "report the retained heap size of compiled schemas")
+
+// retainedHeap returns the heap retained, per copy, by copies values made
+// by allocate, measured after a GC on both sides so that only what is kept
+// reachable counts. Each side collects twice, as sync.Pool only lets go of
+// its buffers, encoding/json's among them, on the second GC.
+func retainedHeap(allocate func() interface{}, copies int) int64 {
+	kept := make([]interface{}, copies)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := range kept {
+		kept[i] = allocate()
+	}
+	runtime.GC()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(kept)
+	retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	if retained < 0 {
+		return 0
+	}
+	return retained / int64(copies)
+}
+
+// schemaFootprint is the heap a compiled schema keeps once it has
+// validated instance, not counting the JSON it was compiled from. qri
+// resolves $ref and builds what it caches on the first Validate, so a
+// schema that never validated would look smaller than a cached one is.
+func schemaFootprint(schema ojson.Anything, instance interface{}, copies int) (int64, error) {
+	data := ojson.MustMarshal(schema)
+	if _, err := compileHugeSchema(schema); err != nil {
+		return 0, err
+	}
+	return retainedHeap(func() interface{} {
+		compiled, _ := compileHugeSchema(mustUnmarshal(string(data)))
+		compiled.Validate(context.Background(), instance)
+		return compiled
+	}, copies), nil
+}
+
+// TestMemoryFootprint reports how much heap each compiled schema retains,
+// and so how many fit a cache of a given size. Run it with -v.
+func TestMemoryFootprint(t *testing.T) {
+	if !*memoryReport {
+		t.Skip("run with -memory-report -v to report compiled schema sizes")
+	}
+	report := new(strings.Builder)
+	table := tabwriter.NewWriter(report, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "schema\tretained bytes\tper 100 MiB\t")
+	row := func(name string, schema ojson.Anything, instance interface{}, copies int) {
+		footprint, err := schemaFootprint(schema, instance, copies)
+		require.NoError(t, err, name)
+		perCache := "-"
+		if footprint > 0 {
+			perCache = fmt.Sprint(100 << 20 / footprint)
+		}
+		fmt.Fprintf(table, "%s\t%d\t%s\t\n", name, footprint, perCache)
+	}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			var instance interface{}
+			if len(schemaCase.validationCases) > 0 {
+				instance = schemaCase.validationCases[0].actual
+			}
+			row(schemaSuite.name+"/"+schemaCase.name, schemaCase.schema, instance, 1000)
+		}
+	}
+	for _, hugeSchema := range hugeSchemas {
+		row(fmt.Sprintf("%s %d", hugeSchema.name, hugeSchema.size), hugeSchema.generate(hugeSchema.size), hugeSchema.invalid, 10)
+	}
+	require.NoError(t, table.Flush())
+	t.Logf("retained heap of compiled schemas:\n%s", report)
+}
+
+func TestRetainedHeap(t *testing.T) {
+	retained := retainedHeap(func() interface{} { return make([]byte, 1<<20) }, 10)
+	require.InDelta(t, 1<<20, retained, 1<<17)
+	// The runtime keeps a few bytes of its own between the two GCs.
+	require.Less(t, retainedHeap(func() interface{} {
+		_ = make([]byte, 1<<20)
+		return nil
+	}, 10), int64(1<<10))
+}
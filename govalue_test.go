@@ -0,0 +1,188 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// normalizeValue turns an in-memory instance into what decoding its JSON
+// encoding would produce, so that time.Time, []byte, json.Number,
+// json.RawMessage and other json.Marshaler values are validated by their
+// JSON representation rather than by their Go kind.
+func normalizeValue(value interface{}) (interface{}, error) {
+	if err := checkJSONValue(value); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// validateNormalized validates an in-memory instance after normalizeValue.
+func validateNormalized(ctx context.Context, schema *jsonschema.Schema, instance interface{}) ([]jsonschema.KeyError, error) {
+	normalized, err := normalizeValue(instance)
+	if err != nil {
+		return nil, err
+	}
+	return *schema.Validate(ctx, normalized).Errs, nil
+}
+
+type goStatus int
+
+const (
+	goStatusActive goStatus = iota
+	goStatusInactive
+)
+
+func (s goStatus) MarshalJSON() ([]byte, error) {
+	if s == goStatusActive {
+		return json.Marshal("active")
+	}
+	return json.Marshal("inactive")
+}
+
+type goPoint struct {
+	X, Y int
+}
+
+func (p goPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]int{p.X, p.Y})
+}
+
+var goValueTime = time.Date(2021, time.January, 2, 3, 4, 5, 0, time.UTC)
+
+// goValueCases compare validating Go values as they are (raw), which goes
+// by their Go kind, with validating them normalized, which goes by their
+// JSON encoding.
+var goValueCases = []struct {
+	name       string
+	spec       []string
+	schema     ojson.Anything
+	actual     ojson.Anything
+	raw        []jsonschema.KeyError
+	normalized []jsonschema.KeyError
+}{
+	{
+		name:   "time.Time as string",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojsonschema.String{},
+		actual: goValueTime,
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: goValueTime, Message: "type should be string, got object"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name: "time.Time in object",
+		spec: []string{"core §9.3.2.1 properties", "validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojsonschema.Object{
+			Properties: ojson.Object{"created": ojsonschema.String{}},
+		},
+		actual: ojson.Object{"created": goValueTime},
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/created", InvalidValue: goValueTime, Message: "type should be string, got object"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name:   "[]byte as base64 string",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojsonschema.String{},
+		actual: []byte("hello"),
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: []byte("hello"), Message: "type should be string, got array"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name:   "json.Number as integer",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "integer"},
+		actual: json.Number("42"),
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: json.Number("42"), Message: "type should be integer, got string"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name:   "fractional json.Number as integer",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "integer"},
+		actual: json.Number("1.5"),
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: json.Number("1.5"), Message: "type should be integer, got string"},
+		},
+		normalized: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: 1.5, Message: "type should be integer, got number"},
+		},
+	},
+	{
+		name:   "json.RawMessage object",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "object"},
+		actual: json.RawMessage(`{"field": "hello"}`),
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: json.RawMessage(`{"field": "hello"}`), Message: "type should be object, got array"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name:   "json.Marshaler enum as string",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojsonschema.String{},
+		actual: goStatusInactive,
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: goStatusInactive, Message: "type should be string, got integer"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+	{
+		name:   "json.Marshaler struct as array",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "array"},
+		actual: goPoint{X: 1, Y: 2},
+		raw: []jsonschema.KeyError{
+			{PropertyPath: "/", InvalidValue: goPoint{X: 1, Y: 2}, Message: "type should be array, got object"},
+		},
+		normalized: []jsonschema.KeyError{},
+	},
+}
+
+func TestGoValueCases(t *testing.T) {
+	for _, goValueCase := range goValueCases {
+		t.Run(goValueCase.name, func(t *testing.T) {
+			schema := compileSchema(t, goValueCase.schema)
+			t.Run("raw", func(t *testing.T) {
+				state := schema.Validate(context.Background(), goValueCase.actual)
+				require.Equal(t, goValueCase.raw, *state.Errs)
+			})
+			t.Run("normalized", func(t *testing.T) {
+				errs, err := validateNormalized(context.Background(), schema, goValueCase.actual)
+				require.NoError(t, err)
+				require.Equal(t, goValueCase.normalized, errs)
+			})
+			t.Run("normalized matches encoded JSON", func(t *testing.T) {
+				errs, err := validateNormalized(context.Background(), schema, goValueCase.actual)
+				require.NoError(t, err)
+				encodedErrs, err := schema.ValidateBytes(context.Background(), ojson.MustMarshal(goValueCase.actual))
+				require.NoError(t, err)
+				require.Equal(t, encodedErrs, errs)
+			})
+		})
+	}
+}
+
+func TestNormalizeValueRejectsNonJSONValues(t *testing.T) {
+	_, err := normalizeValue(ojson.Object{"created": goValueTime, "chan": make(chan int)})
+	require.EqualError(t, err, "/chan: unsupported type chan int")
+}
@@ -0,0 +1,338 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// resolvePointer returns the member of a decoded JSON value at a JSON
+// Pointer, "" being the value itself.
+func resolvePointer(value interface{}, pointer string) (interface{}, bool) {
+	if pointer == "" {
+		return value, true
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, false
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch container := value.(type) {
+		case map[string]interface{}:
+			member, ok := container[token]
+			if !ok {
+				return nil, false
+			}
+			value = member
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(container) {
+				return nil, false
+			}
+			value = container[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// sameInstanceOnPurpose lists the validation cases, by suite, schema case
+// and name, that repeat an instance seen before on purpose: the same value
+// built another way documents that the way it is built makes no difference.
+var sameInstanceOnPurpose = map[string]string{
+	"unicode schema cases/object: required non-ASCII property name, no additional properties/NFC spelling decoded from an escape": "decoded from an escape",
+	"unicode schema cases/object: empty and NUL property names/both present, decoded from escapes":                                "decoded from escapes",
+	"integer schema cases/integer: decoded JSON/1.0":                                                                              "decoded JSON mirrors float64 1.0",
+	"integer schema cases/integer: decoded JSON/1e2":                                                                              "decoded JSON mirrors float64 1e2",
+	"integer schema cases/integer: decoded JSON/-0":                                                                               "decoded JSON mirrors negative zero",
+	"integer schema cases/integer: decoded JSON/1.5":                                                                              "decoded JSON mirrors float64 1.5",
+	"integer schema cases/integer: decoded JSON/uint64 max":                                                                       "decoded JSON mirrors float64 uint64 max",
+	"Go value cases/fractional json.Number as integer/raw":                                                                        "json.Number as built rather than decoded",
+	"Go value cases/fractional json.Number as integer/normalized":                                                                 "normalizing gives the float64 the integer suite has",
+	"Markdown examples/docs/examples.md:56/docs/examples.md:60":                                                                   "the const suite case, documented",
+	"Markdown examples/docs/examples.md:56/docs/examples.md:64":                                                                   "the const suite case, documented",
+}
+
+// unbalancedOnPurpose lists the schema cases, by suite and name, that are
+// not shown both to accept and to reject an instance, and why that is fine.
+var unbalancedOnPurpose = map[string]string{
+	"Go value cases/fractional json.Number as integer": "a fractional number is no integer, raw or normalized",
+}
+
+// suiteHygiene collects what makes schema suites less meaningful than they
+// look: cases that cannot be told apart, schemas that are never shown to
+// accept or to reject anything, and expected errors that do not point at
+// their invalid value.
+type suiteHygiene struct {
+	onPurpose  map[string]string
+	unbalanced map[string]string
+	suiteNames map[string]bool
+	pairs      map[string]string
+	issues     []string
+}
+
+func newSuiteHygiene(onPurpose, unbalanced map[string]string) *suiteHygiene {
+	return &suiteHygiene{
+		onPurpose:  onPurpose,
+		unbalanced: unbalanced,
+		suiteNames: map[string]bool{},
+		pairs:      map[string]string{},
+	}
+}
+
+func (h *suiteHygiene) report(format string, args ...interface{}) {
+	h.issues = append(h.issues, fmt.Sprintf(format, args...))
+}
+
+func (h *suiteHygiene) check(suiteName string, schemaCases []schemaCase) {
+	if h.suiteNames[suiteName] {
+		h.report("%s: duplicate suite name", suiteName)
+	}
+	h.suiteNames[suiteName] = true
+	schemaNames := map[string]bool{}
+	for _, schemaCase := range schemaCases {
+		prefix := suiteName + "/" + schemaCase.name
+		if schemaNames[schemaCase.name] {
+			h.report("%s: duplicate schema case name", prefix)
+		}
+		schemaNames[schemaCase.name] = true
+		schemaData := string(ojson.MustMarshal(schemaCase.schema))
+		validationNames := map[string]bool{}
+		valid, invalid := 0, 0
+		for _, validationCase := range schemaCase.validationCases {
+			name := prefix + "/" + validationCase.name
+			if validationNames[validationCase.name] {
+				h.report("%s: duplicate validation case name", name)
+			}
+			validationNames[validationCase.name] = true
+			pair := schemaData + "\n" + fmt.Sprintf("%#v", validationCase.actual)
+			if previous, ok := h.pairs[pair]; ok && h.onPurpose[name] == "" {
+				h.report("%s: same schema and instance as %s", name, previous)
+			} else if !ok {
+				h.pairs[pair] = name
+			}
+			if len(validationCase.expected) == 0 {
+				valid++
+			} else {
+				invalid++
+			}
+			h.checkInvalidValues(name, validationCase)
+		}
+		if valid == 0 && h.unbalanced[prefix] == "" {
+			h.report("%s: no valid case", prefix)
+		}
+		if invalid == 0 && h.unbalanced[prefix] == "" {
+			h.report("%s: no invalid case", prefix)
+		}
+	}
+}
+
+// checkInvalidValues compares the JSON representations, as that is what
+// the schema sees, so ojson.Object and map[string]interface{} are alike.
+func (h *suiteHygiene) checkInvalidValues(name string, validationCase validationCase) {
+	instance, err := normalizeValue(validationCase.actual)
+	if err != nil {
+		h.report("%s: instance is not JSON: %s", name, err)
+		return
+	}
+	for _, expected := range validationCase.expected {
+		pointer := expected.PropertyPath
+		if pointer == "/" {
+			// qri's root, which a top-level empty key cannot be told from.
+			pointer = ""
+		}
+		atPath, ok := resolvePointer(instance, pointer)
+		if !ok {
+			h.report("%s: no instance value at %s", name, expected.PropertyPath)
+			continue
+		}
+		invalidValue, err := normalizeValue(expected.InvalidValue)
+		if err != nil || !reflect.DeepEqual(atPath, invalidValue) {
+			h.report("%s: invalid value %#v is not the instance value at %s",
+				name, expected.InvalidValue, expected.PropertyPath)
+		}
+	}
+}
+
+// exactSuiteCases turns exact cases into schema cases for the hygiene
+// checks, decoding the instances the way runExactCases does.
+func exactSuiteCases(t *testing.T, exactCases []exactSchemaCase) []schemaCase {
+	var converted []schemaCase
+	for _, exactCase := range exactCases {
+		schemaCase := schemaCase{name: exactCase.name, schema: exactCase.schema}
+		for _, exactValidationCase := range exactCase.validationCases {
+			instance, err := decodeExact([]byte(exactValidationCase.actual))
+			require.NoError(t, err, exactValidationCase.actual)
+			schemaCase.validationCases = append(schemaCase.validationCases, validationCase{
+				name:     exactValidationCase.name,
+				expected: exactValidationCase.expected,
+				actual:   instance,
+			})
+		}
+		converted = append(converted, schemaCase)
+	}
+	return converted
+}
+
+// additionalPropertiesSuiteCases turns additionalProperties states into
+// schema cases for the hygiene checks, with the schema
+// TestAdditionalPropertiesStates builds.
+func additionalPropertiesSuiteCases() []schemaCase {
+	var converted []schemaCase
+	for _, additionalPropertiesState := range additionalPropertiesStates {
+		converted = append(converted, schemaCase{
+			name: additionalPropertiesState.name,
+			schema: ojsonschema.Object{
+				AdditionalProperties: additionalPropertiesState.additionalProperties,
+				Properties: ojson.Object{
+					"field": ojsonschema.String{},
+				},
+			},
+			validationCases: additionalPropertiesState.validationCases,
+		})
+	}
+	return converted
+}
+
+// goValueSuiteCases turns every Go value case into a schema case validating
+// the raw value and its normalized form.
+func goValueSuiteCases(t *testing.T) []schemaCase {
+	var converted []schemaCase
+	for _, goValueCase := range goValueCases {
+		normalized, err := normalizeValue(goValueCase.actual)
+		require.NoError(t, err, goValueCase.name)
+		converted = append(converted, schemaCase{
+			name:   goValueCase.name,
+			schema: goValueCase.schema,
+			validationCases: []validationCase{
+				{name: "raw", expected: goValueCase.raw, actual: goValueCase.actual},
+				{name: "normalized", expected: goValueCase.normalized, actual: normalized},
+			},
+		})
+	}
+	return converted
+}
+
+// markdownSuiteCases turns Markdown examples into schema cases. The blocks
+// only state whether an instance is valid, so the errors qri reports for
+// the invalid ones stand in for expected errors.
+func markdownSuiteCases(t *testing.T) []schemaCase {
+	examples, err := markdownFileExamples()
+	require.NoError(t, err)
+	var converted []schemaCase
+	for _, example := range examples {
+		schemaCase := schemaCase{name: example.name, schema: json.RawMessage(example.schema)}
+		schema := compileSchema(t, schemaCase.schema)
+		for _, instance := range example.instances {
+			var actual interface{}
+			require.NoError(t, json.Unmarshal([]byte(instance.data), &actual), instance.name)
+			expected := []jsonschema.KeyError{}
+			if !instance.valid {
+				expected = *schema.Validate(context.Background(), actual).Errs
+				require.NotEmpty(t, expected, instance.name)
+			}
+			schemaCase.validationCases = append(schemaCase.validationCases, validationCase{
+				name:     instance.name,
+				expected: expected,
+				actual:   actual,
+			})
+		}
+		converted = append(converted, schemaCase)
+	}
+	return converted
+}
+
+func TestSuiteHygiene(t *testing.T) {
+	hygiene := newSuiteHygiene(sameInstanceOnPurpose, unbalancedOnPurpose)
+	for _, schemaSuite := range schemaSuites {
+		hygiene.check(schemaSuite.name, schemaSuite.schemaCases)
+	}
+	hygiene.check("exact number cases", exactSuiteCases(t, exactNumberCases))
+	hygiene.check("exact integer cases", exactSuiteCases(t, exactIntegerCases))
+	hygiene.check("additionalProperties states", additionalPropertiesSuiteCases())
+	hygiene.check("Go value cases", goValueSuiteCases(t))
+	hygiene.check("Markdown examples", markdownSuiteCases(t))
+	require.Empty(t, hygiene.issues)
+}
+
+func TestSuiteHygieneIssues(t *testing.T) {
+	typeError := jsonschema.KeyError{PropertyPath: "/field", InvalidValue: 42, Message: "type should be string, got integer"}
+	hygiene := newSuiteHygiene(
+		map[string]string{"other suite/object/valid": "on purpose"},
+		map[string]string{"other suite/unbalanced": "on purpose"},
+	)
+	hygiene.check("suite", []schemaCase{
+		{
+			name:   "object",
+			schema: ojsonschema.Object{Properties: ojson.Object{"field": ojsonschema.String{}}},
+			validationCases: []validationCase{
+				{name: "valid", actual: ojson.Object{"field": "hello"}, expected: []jsonschema.KeyError{}},
+				{name: "invalid", actual: ojson.Object{"field": 42}, expected: []jsonschema.KeyError{typeError}},
+				{name: "invalid", actual: ojson.Object{"field": 43}, expected: []jsonschema.KeyError{typeError}},
+				{name: "missing", actual: ojson.Object{}, expected: []jsonschema.KeyError{typeError}},
+			},
+		},
+		{
+			name:   "object",
+			schema: ojsonschema.Object{Properties: ojson.Object{"field": ojsonschema.String{}}},
+			validationCases: []validationCase{
+				{name: "valid again", actual: ojson.Object{"field": "hello"}, expected: []jsonschema.KeyError{}},
+			},
+		},
+	})
+	hygiene.check("other suite", []schemaCase{
+		{
+			name:   "object",
+			schema: ojsonschema.Object{Properties: ojson.Object{"field": ojsonschema.String{}}},
+			validationCases: []validationCase{
+				{name: "valid", actual: ojson.Object{"field": "hello"}, expected: []jsonschema.KeyError{}},
+				{name: "invalid", actual: ojson.Object{"field": 42}, expected: []jsonschema.KeyError{typeError}},
+			},
+		},
+		{
+			name:   "unbalanced",
+			schema: ojsonschema.Object{Properties: ojson.Object{"field": ojsonschema.String{}}},
+			validationCases: []validationCase{
+				{name: "valid", actual: ojson.Object{"field": "world"}, expected: []jsonschema.KeyError{}},
+			},
+		},
+	})
+	hygiene.check("suite", nil)
+	require.Equal(t, []string{
+		"suite/object/invalid: duplicate validation case name",
+		"suite/object/invalid: invalid value 42 is not the instance value at /field",
+		"suite/object/missing: no instance value at /field",
+		"suite/object: duplicate schema case name",
+		"suite/object/valid again: same schema and instance as suite/object/valid",
+		"suite/object: no invalid case",
+		"other suite/object/invalid: same schema and instance as suite/object/invalid",
+		"suite: duplicate suite name",
+	}, hygiene.issues)
+}
+
+func TestResolvePointer(t *testing.T) {
+	instance := mustUnmarshal(`{"a/b": [1, {"~": "x"}], "": 2}`)
+	for pointer, expected := range map[string]interface{}{
+		"":           instance,
+		"/":          2.0,
+		"/a~1b/0":    1.0,
+		"/a~1b/1/~0": "x",
+	} {
+		value, ok := resolvePointer(instance, pointer)
+		require.True(t, ok, pointer)
+		require.Equal(t, expected, value, pointer)
+	}
+	for _, pointer := range []string{"a~1b", "/missing", "/a~1b/2", "/a~1b/-1", "/a~1b/0/x", "//"} {
+		_, ok := resolvePointer(instance, pointer)
+		require.False(t, ok, pointer)
+	}
+}
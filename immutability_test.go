@@ -0,0 +1,116 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/stretchr/testify/require"
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+// deepCopy copies maps, slices, pointers and structs, unexported fields
+// included, so that no backing array or map is shared with the original.
+// Channels and funcs are shared.
+func deepCopy(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return deepCopyValue(reflect.ValueOf(value)).Interface()
+}
+
+func deepCopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopyValue(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopyValue(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < c.NumField(); i++ {
+			field := c.Field(i)
+			field = reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem()
+			field.Set(deepCopyValue(field))
+		}
+		return c
+	}
+	return v
+}
+
+func TestDeepCopy(t *testing.T) {
+	original := ojson.Object{
+		"array":   ojson.Array{"one", ojson.Object{"two": 2}},
+		"ordered": orderedObject{{"key", ojson.Array{1}}},
+		"builder": ojsonschema.String{Enum: ojson.Array{"one"}},
+	}
+	copied := deepCopy(original).(ojson.Object)
+	require.Equal(t, original, copied)
+
+	copied["array"].(ojson.Array)[1].(ojson.Object)["two"] = 3
+	copied["ordered"].(orderedObject)[0].value.(ojson.Array)[0] = 2
+	copied["builder"].(ojsonschema.String).Enum.(ojson.Array)[0] = "two"
+	require.Equal(t, ojson.Object{
+		"array":   ojson.Array{"one", ojson.Object{"two": 2}},
+		"ordered": orderedObject{{"key", ojson.Array{1}}},
+		"builder": ojsonschema.String{Enum: ojson.Array{"one"}},
+	}, original)
+}
+
+func TestSharedArraysAreNotMutated(t *testing.T) {
+	// Spare capacity past the shared slices would be overwritten by any
+	// append done on them in place.
+	backing := ojson.Array{"one", "two", "three", "spare"}
+	enum := backing[:3]
+	required := ojson.Array{"field", "spare"}[:1]
+	schemas := []ojson.Anything{
+		ojsonschema.String{Enum: enum},
+		ojsonschema.String{Enum: enum[:2]},
+		ojsonschema.Object{
+			Properties: ojson.Object{"field": ojsonschema.String{Enum: enum}},
+			Required:   required,
+		},
+		ojsonschema.Object{
+			AdditionalProperties: false,
+			Properties:           ojson.Object{"field": ojsonschema.String{Enum: enum}},
+			Required:             required,
+		},
+	}
+	for _, schema := range schemas {
+		compiled := compileSchema(t, schema)
+		compiled.Validate(context.Background(), "four")
+		compiled.Validate(context.Background(), ojson.Object{"field": "four", "other": "one"})
+	}
+	require.Equal(t, ojson.Array{"one", "two", "three", "spare"}, backing)
+	require.Equal(t, ojson.Array{"field", "spare"}, required[:2])
+}
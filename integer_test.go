@@ -0,0 +1,152 @@
+package ojsonschema_tests
+
+import (
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/qri-io/jsonschema"
+	"math"
+	"testing"
+)
+
+// integerSchemaCases pin down which numbers qri accepts as integers. A
+// float64 counts as an integer when converting it to int and back is
+// lossless, so 1.0 and -0 are integers, while floats beyond the int range,
+// including uint64 max once decoded from JSON, are reported as numbers.
+var integerSchemaCases = []schemaCase{
+	{
+		name:          "integer: Go numbers",
+		spec:          []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema:        ojson.Object{"type": "integer"},
+		emptyAccepted: []string{`0`},
+		validationCases: []validationCase{
+			{
+				name:     "float64 1.0",
+				actual:   1.0,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "float64 1e2",
+				actual:   1e2,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "negative zero",
+				actual:   math.Copysign(0, -1),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "int64 2^53+1",
+				actual:   int64(9007199254740993),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "uint64 max",
+				actual:   uint64(math.MaxUint64),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "float64 1.5",
+				actual: 1.5,
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: 1.5, Message: "type should be integer, got number"},
+				},
+			},
+			{
+				name:   "float64 uint64 max",
+				actual: float64(math.MaxUint64),
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: float64(math.MaxUint64), Message: "type should be integer, got number"},
+				},
+			},
+		},
+	},
+	{
+		name:          "integer: decoded JSON",
+		spec:          []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema:        ojson.Object{"type": "integer"},
+		emptyAccepted: []string{`0`},
+		validationCases: []validationCase{
+			{
+				name:     "1.0",
+				actual:   mustUnmarshal(`1.0`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "1e2",
+				actual:   mustUnmarshal(`1e2`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "-0",
+				actual:   mustUnmarshal(`-0`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "2^53+1 rounds to 2^53",
+				actual:   mustUnmarshal(`9007199254740993`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "1.5",
+				actual: mustUnmarshal(`1.5`),
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: 1.5, Message: "type should be integer, got number"},
+				},
+			},
+			{
+				name:   "uint64 max",
+				actual: mustUnmarshal(`18446744073709551615`),
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: 18446744073709551615.0, Message: "type should be integer, got number"},
+				},
+			},
+			{
+				name:   "1e300",
+				actual: mustUnmarshal(`1e300`),
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: 1e300, Message: "type should be integer, got number"},
+				},
+			},
+		},
+	},
+}
+
+func TestIntegerSchemaCases(t *testing.T) {
+	runSchemaCases(t, integerSchemaCases)
+}
+
+// exactIntegerCases are the decoded JSON integer cases on the exact path,
+// where any number without a fractional part is an integer.
+var exactIntegerCases = []exactSchemaCase{
+	{
+		name:   "integer: exact",
+		spec:   []string{"validation §6.1.1 type", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "integer"},
+		validationCases: []exactValidationCase{
+			{name: "1.0", actual: `1.0`, expected: []jsonschema.KeyError{}},
+			{name: "1e2", actual: `1e2`, expected: []jsonschema.KeyError{}},
+			{name: "-0", actual: `-0`, expected: []jsonschema.KeyError{}},
+			{name: "2^53+1", actual: `9007199254740993`, expected: []jsonschema.KeyError{}},
+			{name: "uint64 max", actual: `18446744073709551615`, expected: []jsonschema.KeyError{}},
+			{name: "1e300", actual: `1e300`, expected: []jsonschema.KeyError{}},
+			{
+				name:   "1.5",
+				actual: `1.5`,
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: json.Number("1.5"), Message: "type should be integer, got number"},
+				},
+			},
+			{
+				name:   "1e-300",
+				actual: `1e-300`,
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: json.Number("1e-300"), Message: "type should be integer, got number"},
+				},
+			},
+		},
+	},
+}
+
+func TestExactIntegerCases(t *testing.T) {
+	runExactCases(t, exactIntegerCases)
+}
@@ -0,0 +1,218 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	leakIterations = 2000
+	// heapSlack is how far the heap may end up above its baseline without
+	// that being taken for a leak.
+	heapSlack = 1 << 20
+)
+
+// waitForGoroutines waits for the goroutine count to fall back to
+// baseline, as exiting goroutines take a moment, and returns the count.
+func waitForGoroutines(baseline int, timeout time.Duration) int {
+	deadline := time.Now().Add(timeout)
+	for {
+		count := runtime.NumGoroutine()
+		if count <= baseline || time.Now().After(deadline) {
+			return count
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func heapAfterGC() uint64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// leakContexts are the contexts validations run with: a live one, one
+// cancelled before validation starts and one past its deadline.
+func leakContexts() []context.Context {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	cancel()
+	return []context.Context{context.Background(), cancelled, expired}
+}
+
+type compiledValidationCase struct {
+	schema         *jsonschema.Schema
+	validationCase validationCase
+}
+
+func TestValidationDoesNotLeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("validates every case thousands of times")
+	}
+	var compiled []compiledValidationCase
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			schema := compileSchema(t, schemaCase.schema)
+			for _, validationCase := range schemaCase.validationCases {
+				compiled = append(compiled, compiledValidationCase{schema: schema, validationCase: validationCase})
+			}
+		}
+	}
+	contexts := leakContexts()
+	validateAll := func(iterations int) {
+		for i := 0; i < iterations; i++ {
+			ctx := contexts[i%len(contexts)]
+			for _, c := range compiled {
+				state := c.schema.Validate(ctx, c.validationCase.actual)
+				require.Equal(t, len(c.validationCase.expected), len(*state.Errs), c.validationCase.name)
+			}
+		}
+	}
+
+	validateAll(100)
+	goroutines := runtime.NumGoroutine()
+	heap := heapAfterGC()
+	validateAll(leakIterations)
+	require.LessOrEqual(t, waitForGoroutines(goroutines, time.Second), goroutines, "goroutines leaked")
+	require.Less(t, heapAfterGC(), heap+heapSlack, "heap grew")
+}
+
+// TestRemoteRefDoesNotLeak fetches a remote $ref at a URL of its own on
+// every iteration, as qri caches fetched schemas by URL in a global
+// registry, and resets that registry so that the cache is not taken for a
+// leak; TestRemoteRefRegistryGrowth measures the cache. The fetch runs with
+// a live context, the validations that follow with each of leakContexts.
+func TestRemoteRefDoesNotLeak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("resolves a remote $ref thousands of times")
+	}
+	defer jsonschema.ResetSchemaRegistry()
+	goroutines := runtime.NumGoroutine()
+	var hits int64
+	server := schemaStringServer(&hits)
+	contexts := leakContexts()
+	fetched := 0
+	validateAll := func(iterations int) {
+		for i := 0; i < iterations; i++ {
+			jsonschema.ResetSchemaRegistry()
+			schemaData := ojson.MustMarshal(ojson.Object{"$ref": fmt.Sprintf("%s/string-%d.json", server.URL, fetched)})
+			fetched++
+			schema := new(jsonschema.Schema)
+			require.NoError(t, schema.UnmarshalJSON(schemaData))
+			require.Empty(t, *schema.Validate(context.Background(), "hello").Errs)
+			ctx := contexts[i%len(contexts)]
+			require.Empty(t, *schema.Validate(ctx, "hello").Errs)
+			require.Len(t, *schema.Validate(ctx, 42).Errs, 1)
+		}
+	}
+
+	validateAll(100)
+	heap := heapAfterGC()
+	validateAll(leakIterations)
+	require.Less(t, heapAfterGC(), heap+heapSlack, "heap grew")
+	require.Equal(t, int64(fetched), atomic.LoadInt64(&hits), "every iteration fetches its own $ref")
+
+	server.Close()
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+	require.LessOrEqual(t, waitForGoroutines(goroutines, time.Second), goroutines, "goroutines leaked")
+}
+
+// schemaStringServer serves {"type": "string"} at every path and counts
+// the requests.
+func schemaStringServer(hits *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(hits, 1)
+		w.Header().Set("Content-Type", "application/schema+json")
+		_, _ = w.Write([]byte(`{"type": "string"}`))
+	}))
+}
+
+// TestRemoteRefRegistryGrowth fetches a remote $ref at a URL of its own on
+// every iteration without resetting the registry, which keeps every fetched
+// schema for the life of the process, and reports what each one costs.
+// Validating again does not fetch again nor grow the heap.
+func TestRemoteRefRegistryGrowth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("resolves a remote $ref thousands of times")
+	}
+	jsonschema.ResetSchemaRegistry()
+	defer jsonschema.ResetSchemaRegistry()
+	var hits int64
+	server := schemaStringServer(&hits)
+	defer server.Close()
+	schemas := make([]*jsonschema.Schema, leakIterations)
+	for i := range schemas {
+		schemas[i] = new(jsonschema.Schema)
+		schemaData := ojson.MustMarshal(ojson.Object{"$ref": fmt.Sprintf("%s/string-%d.json", server.URL, i)})
+		require.NoError(t, schemas[i].UnmarshalJSON(schemaData))
+	}
+	validateAll := func() {
+		for _, schema := range schemas {
+			require.Len(t, *schema.Validate(context.Background(), 42).Errs, 1)
+		}
+	}
+
+	heap := heapAfterGC()
+	validateAll()
+	grown := heapAfterGC()
+	for i := range schemas {
+		require.NotNil(t, jsonschema.GetSchemaRegistry().GetKnown(fmt.Sprintf("%s/string-%d.json", server.URL, i)))
+	}
+	require.Equal(t, int64(leakIterations), atomic.LoadInt64(&hits))
+	t.Logf("registry grew by %d bytes for %d fetched schemas, %d bytes each",
+		int64(grown)-int64(heap), leakIterations, (int64(grown)-int64(heap))/leakIterations)
+
+	validateAll()
+	require.Equal(t, int64(leakIterations), atomic.LoadInt64(&hits), "fetched schemas are fetched again")
+	require.Less(t, heapAfterGC(), grown+heapSlack, "heap grew without fetching")
+}
+
+// TestRemoteRefFetchWithDoneContexts resolves a remote $ref for the first
+// time with a cancelled or expired context: the request never reaches the
+// server, the ref is reported unresolved, nothing is cached and no
+// goroutine is left behind. The same schema resolves once given a live
+// context, and needs none after that.
+func TestRemoteRefFetchWithDoneContexts(t *testing.T) {
+	jsonschema.ResetSchemaRegistry()
+	defer jsonschema.ResetSchemaRegistry()
+	goroutines := runtime.NumGoroutine()
+	var hits int64
+	server := schemaStringServer(&hits)
+	for i, ctx := range leakContexts()[1:] {
+		url := fmt.Sprintf("%s/string-%d.json", server.URL, i)
+		schema := new(jsonschema.Schema)
+		require.NoError(t, schema.UnmarshalJSON(ojson.MustMarshal(ojson.Object{"$ref": url})))
+		for j := 0; j < 100; j++ {
+			require.Equal(t, []jsonschema.KeyError{
+				{PropertyPath: "/", InvalidValue: "hello", Message: "failed to resolve schema for ref " + url},
+				{PropertyPath: "/", InvalidValue: "hello", Message: "schema is nil"},
+			}, *schema.Validate(ctx, "hello").Errs)
+		}
+		require.Zero(t, atomic.LoadInt64(&hits), ctx.Err())
+		require.Nil(t, jsonschema.GetSchemaRegistry().GetKnown(url), ctx.Err())
+
+		require.Empty(t, *schema.Validate(context.Background(), "hello").Errs)
+		require.Equal(t, int64(1), atomic.LoadInt64(&hits), ctx.Err())
+		require.Len(t, *schema.Validate(ctx, 42).Errs, 1)
+		atomic.StoreInt64(&hits, 0)
+	}
+
+	server.Close()
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+	require.LessOrEqual(t, waitForGoroutines(goroutines, time.Second), goroutines, "goroutines leaked")
+}
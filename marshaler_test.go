@@ -0,0 +1,205 @@
+package ojsonschema_tests
+
+import (
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// enumOf builds an enum schema from the value set of a Go type, using the
+// JSON representation of each value: an ojsonschema.String when every value
+// marshals to a string, a bare enum otherwise. fmt.Stringer is not taken
+// into account, just like encoding/json does not.
+func enumOf(values ...interface{}) (ojson.Anything, error) {
+	enum := ojson.Array{}
+	allStrings := true
+	for _, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		_, isString := decoded.(string)
+		allStrings = allStrings && isString
+		enum = append(enum, decoded)
+	}
+	if allStrings {
+		return ojsonschema.String{Enum: enum}, nil
+	}
+	return ojson.Object{"enum": enum}, nil
+}
+
+func mustEnumOf(values ...interface{}) ojson.Anything {
+	schema, err := enumOf(values...)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+// goColor implements fmt.Stringer only, so it marshals as an integer.
+type goColor int
+
+const (
+	goColorRed goColor = iota
+	goColorBlue
+)
+
+func (c goColor) String() string {
+	if c == goColorRed {
+		return "red"
+	}
+	return "blue"
+}
+
+var marshalerSchemaCases = []schemaCase{
+	{
+		name:          "string: enum of json.Marshaler values",
+		spec:          []string{"validation §6.1.2 enum"},
+		schema:        ojsonschema.String{Enum: ojson.Array{goStatusActive, goStatusInactive}},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "JSON representation",
+				actual:   "active",
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "unknown value",
+				actual: "deleted",
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: "deleted", Message: `should be one of ["active", "inactive"]`},
+				},
+			},
+		},
+	},
+	{
+		name:          "const: json.Marshaler value",
+		spec:          []string{"validation §6.1.3 const"},
+		schema:        ojsonschema.Const(goStatusInactive),
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "JSON representation",
+				actual:   "inactive",
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "other value",
+				actual: "active",
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: "active", Message: `must equal "inactive"`},
+				},
+			},
+		},
+	},
+	{
+		name:          "const: fmt.Stringer value marshals as its underlying integer",
+		spec:          []string{"validation §6.1.3 const"},
+		schema:        ojsonschema.Const(goColorBlue),
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "JSON representation",
+				actual:   mustUnmarshal(`1`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "String() representation",
+				actual: "blue",
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: "blue", Message: `must equal 1`},
+				},
+			},
+		},
+	},
+	{
+		name:          "enum: built from json.Marshaler structs",
+		spec:          []string{"validation §6.1.2 enum", "core §4.2.2 instance equality"},
+		schema:        mustEnumOf(goPoint{X: 0, Y: 0}, goPoint{X: 1, Y: 2}),
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "JSON representation",
+				actual:   mustUnmarshal(`[1, 2]`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "unknown value",
+				actual: mustUnmarshal(`[2, 1]`),
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: []interface{}{2.0, 1.0}, Message: `should be one of [[0,0], [1,2]]`},
+				},
+			},
+		},
+	},
+}
+
+func TestMarshalerSchemaCases(t *testing.T) {
+	runSchemaCases(t, marshalerSchemaCases)
+}
+
+func TestMarshalerSchemasUseJSONRepresentation(t *testing.T) {
+	for _, marshalerCase := range []struct {
+		name     string
+		schema   ojson.Anything
+		keyword  string
+		expected interface{}
+	}{
+		{
+			name:     "enum of json.Marshaler values",
+			schema:   ojsonschema.String{Enum: ojson.Array{goStatusActive, goStatusInactive}},
+			keyword:  "enum",
+			expected: []interface{}{"active", "inactive"},
+		},
+		{
+			name:     "const of json.Marshaler value",
+			schema:   ojsonschema.Const(goStatusInactive),
+			keyword:  "const",
+			expected: "inactive",
+		},
+		{
+			name:     "enum of fmt.Stringer values",
+			schema:   ojsonschema.String{Enum: ojson.Array{goColorRed, goColorBlue}},
+			keyword:  "enum",
+			expected: []interface{}{0.0, 1.0},
+		},
+		{
+			name:     "enumOf json.Marshaler values",
+			schema:   mustEnumOf(goStatusActive, goStatusInactive),
+			keyword:  "enum",
+			expected: []interface{}{"active", "inactive"},
+		},
+		{
+			name:     "enumOf fmt.Stringer values",
+			schema:   mustEnumOf(goColorRed, goColorBlue),
+			keyword:  "enum",
+			expected: []interface{}{0.0, 1.0},
+		},
+	} {
+		t.Run(marshalerCase.name, func(t *testing.T) {
+			members := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(ojson.MustMarshal(marshalerCase.schema), &members))
+			require.Equal(t, marshalerCase.expected, members[marshalerCase.keyword])
+		})
+	}
+}
+
+func TestEnumOf(t *testing.T) {
+	require.Equal(t,
+		ojsonschema.String{Enum: ojson.Array{"active", "inactive"}},
+		mustEnumOf(goStatusActive, goStatusInactive),
+	)
+	require.Equal(t,
+		ojson.Object{"enum": ojson.Array{"active", 1.0}},
+		mustEnumOf(goStatusActive, goColorBlue),
+	)
+	_, err := enumOf(make(chan int))
+	require.Error(t, err)
+}
@@ -0,0 +1,355 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// checkJSONValue reports the first value in an in-memory instance that has
+// no JSON representation: NaN, ±Inf, channels, funcs, complex numbers and
+// unsafe pointers, in maps, slices and the fields encoding/json would
+// encode of structs. The error names the value by its JSON Pointer.
+func checkJSONValue(value interface{}) error {
+	return checkJSONValueAt(reflect.ValueOf(value), "")
+}
+
+func checkJSONValueAt(v reflect.Value, path string) error {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return checkJSONValueAt(v.Elem(), path)
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s: unsupported value %v", propertyPath(path), f)
+		}
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+		return fmt.Errorf("%s: unsupported type %s", propertyPath(path), v.Type())
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkJSONValueAt(iter.Value(), childPath(path, fmt.Sprint(iter.Key()))); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkJSONValueAt(v.Index(i), childPath(path, fmt.Sprint(i))); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "-" || field.PkgPath != "" && !field.Anonymous {
+				continue
+			}
+			fieldPath := path
+			if name != "" || !field.Anonymous || field.Type.Kind() != reflect.Struct {
+				if name == "" {
+					name = field.Name
+				}
+				fieldPath = childPath(path, name)
+			}
+			if err := checkJSONValueAt(v.Field(i), fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateValue validates an in-memory instance, refusing values that could
+// never have come from a JSON document instead of letting them through to
+// the keywords.
+func validateValue(ctx context.Context, schema *jsonschema.Schema, instance interface{}) ([]jsonschema.KeyError, error) {
+	if err := checkJSONValue(instance); err != nil {
+		return nil, err
+	}
+	return *schema.Validate(ctx, instance).Errs, nil
+}
+
+// nonJSONValues lists Go values without a JSON representation. Behavior is
+// the same for each of them:
+//   - in a schema, json.Marshal returns an error and ojson.MustMarshal
+//     panics with "failed to marshal %#v to JSON", dropping that error;
+//   - in an instance, validateValue returns err;
+//   - in an instance passed to Validate directly, qri does not panic, and
+//     a string schema reports a type KeyError. Numeric schemas are another
+//     matter, see nonFiniteNumberCases.
+var nonJSONValues = []struct {
+	name      string
+	value     ojson.Anything
+	marshal   string
+	err       string
+	nestedErr string
+}{
+	{
+		name:      "NaN",
+		value:     math.NaN(),
+		marshal:   "json: unsupported value: NaN",
+		err:       "/: unsupported value NaN",
+		nestedErr: "/field/1: unsupported value NaN",
+	},
+	{
+		name:      "+Inf",
+		value:     math.Inf(1),
+		marshal:   "json: unsupported value: +Inf",
+		err:       "/: unsupported value +Inf",
+		nestedErr: "/field/1: unsupported value +Inf",
+	},
+	{
+		name:      "-Inf",
+		value:     math.Inf(-1),
+		marshal:   "json: unsupported value: -Inf",
+		err:       "/: unsupported value -Inf",
+		nestedErr: "/field/1: unsupported value -Inf",
+	},
+	{
+		name:      "float32 NaN",
+		value:     float32(math.NaN()),
+		marshal:   "json: unsupported value: NaN",
+		err:       "/: unsupported value NaN",
+		nestedErr: "/field/1: unsupported value NaN",
+	},
+	{
+		name:      "channel",
+		value:     make(chan int),
+		marshal:   "json: unsupported type: chan int",
+		err:       "/: unsupported type chan int",
+		nestedErr: "/field/1: unsupported type chan int",
+	},
+	{
+		name:      "func",
+		value:     func() {},
+		marshal:   "json: unsupported type: func()",
+		err:       "/: unsupported type func()",
+		nestedErr: "/field/1: unsupported type func()",
+	},
+	{
+		name:      "complex",
+		value:     complex(1, 2),
+		marshal:   "json: unsupported type: complex128",
+		err:       "/: unsupported type complex128",
+		nestedErr: "/field/1: unsupported type complex128",
+	},
+}
+
+func TestNonJSONValuesInSchemas(t *testing.T) {
+	for _, nonJSONValue := range nonJSONValues {
+		t.Run(nonJSONValue.name, func(t *testing.T) {
+			schemas := map[string]ojson.Anything{
+				"const":        ojsonschema.Const(nonJSONValue.value),
+				"enum":         ojsonschema.String{Enum: ojson.Array{"one", nonJSONValue.value}},
+				"raw":          ojson.Object{"default": nonJSONValue.value},
+				"nested const": ojson.Object{"properties": ojson.Object{"field": ojsonschema.Const(nonJSONValue.value)}},
+			}
+			for name, schema := range schemas {
+				t.Run(name, func(t *testing.T) {
+					_, err := json.Marshal(schema)
+					require.Error(t, err)
+					require.Contains(t, err.Error(), nonJSONValue.marshal)
+					require.PanicsWithValue(t, fmt.Sprintf("failed to marshal %#v to JSON", schema), func() {
+						ojson.MustMarshal(schema)
+					})
+				})
+			}
+		})
+	}
+}
+
+func TestNonJSONValuesInInstances(t *testing.T) {
+	schema := new(jsonschema.Schema)
+	require.NoError(t, json.Unmarshal(ojson.MustMarshal(ojsonschema.String{}), schema))
+	for _, nonJSONValue := range nonJSONValues {
+		t.Run(nonJSONValue.name, func(t *testing.T) {
+			_, err := validateValue(context.Background(), schema, nonJSONValue.value)
+			require.EqualError(t, err, nonJSONValue.err)
+
+			nested := ojson.Object{"field": ojson.Array{"ok", nonJSONValue.value}}
+			_, err = validateValue(context.Background(), schema, nested)
+			require.EqualError(t, err, nonJSONValue.nestedErr)
+
+			inStruct := struct {
+				Field  []interface{} `json:"field"`
+				hidden interface{}
+			}{Field: []interface{}{"ok", nonJSONValue.value}, hidden: nonJSONValue.value}
+			_, err = validateValue(context.Background(), schema, inStruct)
+			require.EqualError(t, err, nonJSONValue.nestedErr)
+
+			require.NotPanics(t, func() {
+				state := schema.Validate(context.Background(), nonJSONValue.value)
+				require.NotEmpty(t, *state.Errs)
+			})
+		})
+	}
+}
+
+// nonFiniteNumberCases pin what qri does with NaN and ±Inf against numeric
+// keywords. DataType calls all three a number, and every comparison with
+// NaN is false, so NaN passes minimum, maximum and exclusive bounds alike:
+// only validateValue keeps it out.
+var nonFiniteNumberCases = []struct {
+	name     string
+	schema   ojson.Anything
+	value    float64
+	messages []string
+}{
+	{
+		name:     "NaN passes minimum",
+		schema:   ojson.Object{"type": "number", "minimum": 0},
+		value:    math.NaN(),
+		messages: []string{},
+	},
+	{
+		name:     "NaN passes maximum",
+		schema:   ojson.Object{"type": "number", "maximum": 0},
+		value:    math.NaN(),
+		messages: []string{},
+	},
+	{
+		name:     "NaN passes exclusiveMinimum",
+		schema:   ojson.Object{"type": "number", "exclusiveMinimum": 0},
+		value:    math.NaN(),
+		messages: []string{},
+	},
+	{
+		name:     "NaN fails multipleOf",
+		schema:   ojson.Object{"type": "number", "multipleOf": 1},
+		value:    math.NaN(),
+		messages: []string{"must be a multiple of 1"},
+	},
+	{
+		name:     "NaN fails const",
+		schema:   ojsonschema.Const(0),
+		value:    math.NaN(),
+		messages: []string{"must equal 0"},
+	},
+	{
+		name:     "+Inf passes minimum",
+		schema:   ojson.Object{"type": "number", "minimum": 0},
+		value:    math.Inf(1),
+		messages: []string{},
+	},
+	{
+		name:     "+Inf fails maximum",
+		schema:   ojson.Object{"type": "number", "maximum": 0},
+		value:    math.Inf(1),
+		messages: []string{"must be less than or equal to 0"},
+	},
+	{
+		name:     "+Inf fails multipleOf",
+		schema:   ojson.Object{"type": "number", "multipleOf": 1},
+		value:    math.Inf(1),
+		messages: []string{"must be a multiple of 1"},
+	},
+	{
+		name:     "-Inf fails minimum",
+		schema:   ojson.Object{"type": "number", "minimum": 0},
+		value:    math.Inf(-1),
+		messages: []string{"must be greater than or equal to 0"},
+	},
+	{
+		name:     "-Inf fails exclusiveMinimum",
+		schema:   ojson.Object{"type": "number", "exclusiveMinimum": 0},
+		value:    math.Inf(-1),
+		messages: []string{"-Inf must be greater than 0"},
+	},
+	{
+		name:     "-Inf passes maximum",
+		schema:   ojson.Object{"type": "number", "maximum": 0},
+		value:    math.Inf(-1),
+		messages: []string{},
+	},
+}
+
+// TestNonFiniteNumbersAgainstNumericSchemas compares messages only, as NaN
+// in InvalidValue never equals itself.
+func TestNonFiniteNumbersAgainstNumericSchemas(t *testing.T) {
+	for _, nonFiniteNumberCase := range nonFiniteNumberCases {
+		t.Run(nonFiniteNumberCase.name, func(t *testing.T) {
+			schema := new(jsonschema.Schema)
+			require.NoError(t, json.Unmarshal(ojson.MustMarshal(nonFiniteNumberCase.schema), schema))
+			messages := []string{}
+			for _, keyError := range *schema.Validate(context.Background(), nonFiniteNumberCase.value).Errs {
+				messages = append(messages, keyError.Message)
+			}
+			require.Equal(t, nonFiniteNumberCase.messages, messages)
+
+			_, err := validateValue(context.Background(), schema, nonFiniteNumberCase.value)
+			require.EqualError(t, err, fmt.Sprintf("/: unsupported value %v", nonFiniteNumberCase.value))
+		})
+	}
+}
+
+func TestValidateValueAcceptsJSONValues(t *testing.T) {
+	schema := new(jsonschema.Schema)
+	require.NoError(t, json.Unmarshal(ojson.MustMarshal(ojsonschema.String{}), schema))
+	errs, err := validateValue(context.Background(), schema, "hello")
+	require.NoError(t, err)
+	require.Equal(t, []jsonschema.KeyError{}, errs)
+
+	_, err = validateValue(context.Background(), schema, ojson.Object{
+		"float":     math.MaxFloat64,
+		"marshaler": json.RawMessage(`{"nested": 1}`),
+		"nil":       (*int)(nil),
+	})
+	require.NoError(t, err)
+}
+
+type nonJSONEmbedded struct {
+	Inner float64
+}
+
+func TestCheckJSONValueStructFields(t *testing.T) {
+	for expected, value := range map[string]interface{}{
+		"/Plain: unsupported value NaN": struct{ Plain float64 }{math.NaN()},
+		"/tagged: unsupported value NaN": struct {
+			Field float64 `json:"tagged,omitempty"`
+		}{math.NaN()},
+		"/Inner: unsupported value +Inf": struct{ nonJSONEmbedded }{nonJSONEmbedded{math.Inf(1)}},
+		"/ptr/Inner: unsupported value NaN": struct {
+			Pointer *nonJSONEmbedded `json:"ptr"`
+		}{&nonJSONEmbedded{math.NaN()}},
+	} {
+		require.EqualError(t, checkJSONValue(value), expected)
+	}
+	for _, value := range []interface{}{
+		struct {
+			Skipped float64 `json:"-"`
+		}{math.NaN()},
+		struct{ unexported float64 }{math.NaN()},
+	} {
+		require.NoError(t, checkJSONValue(value), "%#v", value)
+	}
+}
+
+func TestCheckJSONValueEmptyKeys(t *testing.T) {
+	for expected, value := range map[string]interface{}{
+		"/: unsupported value NaN":     ojson.Object{"": math.NaN()},
+		"//x: unsupported value NaN":   ojson.Object{"": ojson.Object{"x": math.NaN()}},
+		"//: unsupported value NaN":    ojson.Object{"": ojson.Object{"": math.NaN()}},
+		"/a//0: unsupported value NaN": ojson.Object{"a": ojson.Object{"": ojson.Array{math.NaN()}}},
+	} {
+		require.EqualError(t, checkJSONValue(value), expected)
+	}
+}
@@ -0,0 +1,110 @@
+package ojsonschema_tests
+
+import (
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"testing"
+)
+
+// nullSchemaCases tell a property that is null apart from one that is
+// missing: required only checks that the key is present, so null satisfies
+// it, and it is the property schema that decides whether null is allowed.
+// ojson.Object is an alias of map[string]interface{}, the type json.Unmarshal
+// decodes objects into, so each instance stands for all three ways of
+// building it.
+var nullSchemaCases = []schemaCase{
+	{
+		name: "object: required field without property schema",
+		spec: []string{"validation §6.5.3 required"},
+		schema: ojsonschema.Object{
+			Required: ojson.Array{"field"},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "null field",
+				actual:   ojson.Object{"field": nil},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "empty string field",
+				actual:   ojson.Object{"field": ""},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "missing field",
+				actual: ojson.Object{},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: map[string]interface{}{}, Message: `"field" value is required`},
+				},
+			},
+		},
+	},
+	{
+		name: "object: required string field",
+		spec: []string{"validation §6.5.3 required", "core §9.3.2.1 properties", "validation §6.1.1 type"},
+		schema: ojsonschema.Object{
+			Properties: ojson.Object{
+				"field": ojsonschema.String{},
+			},
+			Required: ojson.Array{"field"},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:   "null field",
+				actual: ojson.Object{"field": nil},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/field", InvalidValue: nil, Message: "type should be string, got null"},
+				},
+			},
+			{
+				name:     "empty string field",
+				actual:   ojson.Object{"field": ""},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "missing field",
+				actual: ojson.Object{},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: map[string]interface{}{}, Message: `"field" value is required`},
+				},
+			},
+		},
+	},
+	{
+		name: "object: required nullable field",
+		spec: []string{"validation §6.5.3 required", "validation §6.1.1 type"},
+		schema: ojsonschema.Object{
+			Properties: ojson.Object{
+				"field": ojson.Object{"type": ojson.Array{"string", "null"}},
+			},
+			Required: ojson.Array{"field"},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "null field",
+				actual:   ojson.Object{"field": nil},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "empty string field",
+				actual:   ojson.Object{"field": ""},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "missing field",
+				actual: ojson.Object{},
+				expected: []jsonschema.KeyError{
+					{PropertyPath: "/", InvalidValue: map[string]interface{}{}, Message: `"field" value is required`},
+				},
+			},
+		},
+	},
+}
+
+func TestNullSchemaCases(t *testing.T) {
+	runSchemaCases(t, nullSchemaCases)
+}
@@ -0,0 +1,482 @@
+package ojsonschema_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// decodeExact decodes a single JSON document keeping every number as
+// json.Number, so that no precision is lost before validation.
+func decodeExact(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after top-level value")
+	}
+	return value, nil
+}
+
+// exactRat converts a JSON number to big.Rat. Besides json.Number it
+// accepts finite Go numbers, so in-memory instances go through the same
+// comparisons as decoded ones.
+func exactRat(value interface{}) (*big.Rat, bool) {
+	if number, ok := value.(json.Number); ok {
+		return new(big.Rat).SetString(string(number))
+	}
+	if value == nil {
+		return nil, false
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Rat).SetInt64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint())), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(f), true
+	}
+	return nil, false
+}
+
+// exactType returns the JSON type of value, telling "integer" from
+// "number" by the exact numeric value rather than its float64 rounding.
+func exactType(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	if _, ok := value.(json.Number); ok {
+		if rat, ok := exactRat(value); ok && rat.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	if rat, ok := exactRat(value); ok {
+		if rat.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// exactEqual compares two JSON values, numbers by their exact value.
+func exactEqual(a, b interface{}) bool {
+	if ratA, ok := exactRat(a); ok {
+		ratB, ok := exactRat(b)
+		return ok && ratA.Cmp(ratB) == 0
+	}
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, valueA := range a {
+			valueB, ok := b[key]
+			if !ok || !exactEqual(valueA, valueB) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !exactEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// exactString renders a schema value for error messages.
+func exactString(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// childPath appends a reference token to a JSON Pointer, "" being the root
+// as in RFC 6901, so that an empty key stays a token of its own.
+func childPath(path, token string) string {
+	return path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// propertyPath turns a JSON Pointer into a KeyError PropertyPath, where qri
+// spells the root "/". The root and a top-level empty key look alike there.
+func propertyPath(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
+
+// validateExact validates an instance against a schema, both decoded with
+// decodeExact. It supports type, const, enum, minimum, exclusiveMinimum,
+// maximum, exclusiveMaximum, multipleOf, properties and items, and reports
+// errors with the messages of qri-io/jsonschema, the bounds of exclusive
+// keywords following the value.
+func validateExact(schema, instance interface{}) []jsonschema.KeyError {
+	errs := []jsonschema.KeyError{}
+	validateExactAt(schema, instance, "", &errs)
+	return errs
+}
+
+var exactBounds = []struct {
+	keyword string
+	message string
+	fails   func(cmp int) bool
+}{
+	{"minimum", "must be greater than or equal to %[2]s", func(cmp int) bool { return cmp < 0 }},
+	{"exclusiveMinimum", "%[1]s must be greater than %[2]s", func(cmp int) bool { return cmp <= 0 }},
+	{"maximum", "must be less than or equal to %[2]s", func(cmp int) bool { return cmp > 0 }},
+	{"exclusiveMaximum", "%[1]s must be less than %[2]s", func(cmp int) bool { return cmp >= 0 }},
+}
+
+func validateExactAt(schema, instance interface{}, path string, errs *[]jsonschema.KeyError) {
+	keywords, ok := schema.(map[string]interface{})
+	if !ok {
+		return
+	}
+	addError := func(format string, args ...interface{}) {
+		*errs = append(*errs, jsonschema.KeyError{
+			PropertyPath: propertyPath(path),
+			InvalidValue: instance,
+			Message:      fmt.Sprintf(format, args...),
+		})
+	}
+	if types, ok := keywords["type"]; ok {
+		if !exactTypeMatches(types, exactType(instance)) {
+			addError("type should be %s, got %s", exactTypeString(types), exactType(instance))
+		}
+	}
+	if constant, ok := keywords["const"]; ok && !exactEqual(constant, instance) {
+		addError("must equal %s", exactString(constant))
+	}
+	if enum, ok := keywords["enum"].([]interface{}); ok {
+		found := false
+		values := make([]string, 0, len(enum))
+		for _, value := range enum {
+			found = found || exactEqual(value, instance)
+			values = append(values, exactString(value))
+		}
+		if !found {
+			addError("should be one of [%s]", strings.Join(values, ", "))
+		}
+	}
+	if number, ok := exactRat(instance); ok {
+		for _, bound := range exactBounds {
+			if limit, ok := exactRat(keywords[bound.keyword]); ok && bound.fails(number.Cmp(limit)) {
+				addError(bound.message, exactString(instance), exactString(keywords[bound.keyword]))
+			}
+		}
+		if divisor, ok := exactRat(keywords["multipleOf"]); ok && divisor.Sign() > 0 {
+			if !new(big.Rat).Quo(number, divisor).IsInt() {
+				addError("must be a multiple of %s", exactString(keywords["multipleOf"]))
+			}
+		}
+	}
+	if properties, ok := keywords["properties"].(map[string]interface{}); ok {
+		if object, ok := instance.(map[string]interface{}); ok {
+			for _, key := range sortedKeys(properties) {
+				if value, ok := object[key]; ok {
+					validateExactAt(properties[key], value, childPath(path, key), errs)
+				}
+			}
+		}
+	}
+	if items, ok := keywords["items"]; ok {
+		if array, ok := instance.([]interface{}); ok {
+			for i, value := range array {
+				validateExactAt(items, value, childPath(path, fmt.Sprint(i)), errs)
+			}
+		}
+	}
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func exactTypeMatches(types interface{}, actual string) bool {
+	switch types := types.(type) {
+	case string:
+		return types == actual || types == "number" && actual == "integer"
+	case []interface{}:
+		for _, t := range types {
+			if exactTypeMatches(t, actual) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func exactTypeString(types interface{}) string {
+	if list, ok := types.([]interface{}); ok {
+		names := make([]string, 0, len(list))
+		for _, name := range list {
+			names = append(names, fmt.Sprint(name))
+		}
+		return "one of: " + strings.Join(names, ",")
+	}
+	return fmt.Sprint(types)
+}
+
+type exactValidationCase struct {
+	name     string
+	expected []jsonschema.KeyError
+	actual   string
+}
+
+type exactSchemaCase struct {
+	name            string
+	spec            []string
+	schema          ojson.Anything
+	validationCases []exactValidationCase
+}
+
+var exactNumberCases = []exactSchemaCase{
+	{
+		name:   "integer: minimum above 2^53",
+		spec:   []string{"validation §6.1.1 type", "validation §6.2.4 minimum", "core §4.2.1 instance data model"},
+		schema: ojson.Object{"type": "integer", "minimum": json.Number("9007199254740993")},
+		validationCases: []exactValidationCase{
+			{
+				name:     "equal to minimum",
+				actual:   `9007199254740993`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "2^53 rounds to the same float64 but is below minimum",
+				actual: `9007199254740992`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: json.Number("9007199254740992"),
+						Message:      "must be greater than or equal to 9007199254740993",
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "integer: enum of large IDs",
+		spec: []string{"validation §6.1.2 enum", "core §4.2.2 instance equality"},
+		schema: ojson.Object{"enum": ojson.Array{
+			json.Number("18446744073709551615"),
+			json.Number("9223372036854775807"),
+		}},
+		validationCases: []exactValidationCase{
+			{
+				name:     "uint64 max",
+				actual:   `18446744073709551615`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "same value in exponent notation",
+				actual:   `1.8446744073709551615e19`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "neighbour of int64 max",
+				actual: `9223372036854775806`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: json.Number("9223372036854775806"),
+						Message:      "should be one of [18446744073709551615, 9223372036854775807]",
+					},
+				},
+			},
+		},
+	},
+	{
+		name:   "number: multipleOf cents",
+		spec:   []string{"validation §6.1.1 type", "validation §6.2.1 multipleOf"},
+		schema: ojson.Object{"type": "number", "multipleOf": json.Number("0.01")},
+		validationCases: []exactValidationCase{
+			{
+				name:     "0.07 is a multiple of 0.01",
+				actual:   `0.07`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "large amount",
+				actual:   `123456789012345.67`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "fraction of a cent",
+				actual: `0.075`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: json.Number("0.075"),
+						Message:      "must be a multiple of 0.01",
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "object: monetary amount",
+		spec: []string{
+			"core §9.3.2.1 properties",
+			"validation §6.1.1 type",
+			"validation §6.2.1 multipleOf",
+			"validation §6.2.2 maximum",
+			"validation §6.2.4 minimum",
+			"validation §6.2.5 exclusiveMinimum",
+		},
+		schema: ojson.Object{
+			"type": "object",
+			"properties": ojson.Object{
+				"id": ojson.Object{"type": "integer", "exclusiveMinimum": json.Number("0")},
+				"amount": ojson.Object{
+					"type":       "number",
+					"minimum":    json.Number("0.01"),
+					"maximum":    json.Number("99999999999999.99"),
+					"multipleOf": json.Number("0.01"),
+				},
+			},
+		},
+		validationCases: []exactValidationCase{
+			{
+				name:     "valid payment",
+				actual:   `{"id": 90071992547409931, "amount": 99999999999999.99}`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "amount above maximum by a fraction of a cent",
+				actual: `{"id": 1, "amount": 99999999999999.991}`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/amount",
+						InvalidValue: json.Number("99999999999999.991"),
+						Message:      "must be less than or equal to 99999999999999.99",
+					},
+					{
+						PropertyPath: "/amount",
+						InvalidValue: json.Number("99999999999999.991"),
+						Message:      "must be a multiple of 0.01",
+					},
+				},
+			},
+			{
+				name:   "fractional id",
+				actual: `{"id": 1.5, "amount": 0.01}`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/id",
+						InvalidValue: json.Number("1.5"),
+						Message:      "type should be integer, got number",
+					},
+				},
+			},
+			{
+				name:   "zero id",
+				actual: `{"id": 0, "amount": 0.01}`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/id",
+						InvalidValue: json.Number("0"),
+						Message:      "0 must be greater than 0",
+					},
+				},
+			},
+		},
+	},
+	{
+		name:   "const: decimal",
+		spec:   []string{"validation §6.1.3 const", "core §4.2.2 instance equality"},
+		schema: ojson.Object{"const": json.Number("0.3")},
+		validationCases: []exactValidationCase{
+			{
+				name:     "trailing zero",
+				actual:   `0.30`,
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "float64 sum of 0.1 and 0.2",
+				actual: `0.30000000000000004`,
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: json.Number("0.30000000000000004"),
+						Message:      "must equal 0.3",
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestExactNumberCases(t *testing.T) {
+	runExactCases(t, exactNumberCases)
+}
+
+func runExactCases(t *testing.T, schemaCases []exactSchemaCase) {
+	for _, schemaCase := range schemaCases {
+		t.Run(schemaCase.name, func(t *testing.T) {
+			schema, err := decodeExact(ojson.MustMarshal(schemaCase.schema))
+			require.NoError(t, err)
+			for _, validationCase := range schemaCase.validationCases {
+				t.Run(validationCase.name, func(t *testing.T) {
+					instance, err := decodeExact([]byte(validationCase.actual))
+					require.NoError(t, err)
+					require.Equal(t, validationCase.expected, validateExact(schema, instance))
+				})
+			}
+		})
+	}
+}
+
+func TestDecodeExact(t *testing.T) {
+	value, err := decodeExact([]byte(`{"id": 12345678901234567890}`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"id": json.Number("12345678901234567890")}, value)
+
+	_, err = decodeExact([]byte(`1 2`))
+	require.Error(t, err)
+}
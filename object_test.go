@@ -12,18 +12,28 @@ import (
 
 type validationCase struct {
 	name     string
+	spec     []string
 	expected []jsonschema.KeyError
 	actual   ojson.Anything
 }
 
-var schemaCases = []struct {
+// schemaCase pairs a schema with instances to validate against it.
+// spec references the specification sections covered, see specRequirements,
+// and emptyAccepted lists, by name, which of emptyValues the schema accepts.
+type schemaCase struct {
 	name            string
+	spec            []string
 	schema          ojson.Anything
+	emptyAccepted   []string
 	validationCases []validationCase
-}{
+}
+
+var schemaCases = []schemaCase{
 	{
-		name:   "string: simple",
-		schema: ojsonschema.String{},
+		name:          "string: simple",
+		spec:          []string{"validation §6.1.1 type"},
+		schema:        ojsonschema.String{},
+		emptyAccepted: []string{`""`},
 		validationCases: []validationCase{
 			{
 				name:     "just a string, no errors",
@@ -40,8 +50,10 @@ var schemaCases = []struct {
 		},
 	},
 	{
-		name:   "string: enum",
-		schema: ojsonschema.String{Enum: ojson.Array{"one", "two", "three"}},
+		name:          "string: enum",
+		spec:          []string{"validation §6.1.1 type", "validation §6.1.2 enum"},
+		schema:        ojsonschema.String{Enum: ojson.Array{"one", "two", "three"}},
+		emptyAccepted: []string{},
 		validationCases: []validationCase{
 			{
 				name:     "valid value",
@@ -63,6 +75,7 @@ var schemaCases = []struct {
 	},
 	{
 		name: "object: single required field, no additional properties",
+		spec: []string{"validation §6.5.3 required", "core §9.3.2.1 properties", "core §9.3.2.3 additionalProperties"},
 		schema: ojsonschema.Object{
 			AdditionalProperties: false,
 			Properties: ojson.Object{
@@ -70,6 +83,7 @@ var schemaCases = []struct {
 			},
 			Required: ojson.Array{"field"},
 		},
+		emptyAccepted: []string{},
 		validationCases: []validationCase{
 			{
 				name:     "valid case",
@@ -96,7 +110,9 @@ var schemaCases = []struct {
 	},
 	{
 		name: "const",
+		spec: []string{"validation §6.1.3 const"},
 		schema: ojsonschema.Const("hello"),
+		emptyAccepted: []string{},
 		validationCases: []validationCase{
 			{
 				name: "valid value",
@@ -118,19 +134,55 @@ var schemaCases = []struct {
 	},
 }
 
+// schemaSuites lists every table of schema cases, for checks that run over
+// all of them rather than over a single table.
+var schemaSuites = []struct {
+	name        string
+	schemaCases []schemaCase
+}{
+	{name: "schema cases", schemaCases: schemaCases},
+	{name: "unicode schema cases", schemaCases: unicodeSchemaCases},
+	{name: "ordered schema cases", schemaCases: orderedSchemaCases},
+	{name: "null schema cases", schemaCases: nullSchemaCases},
+	{name: "integer schema cases", schemaCases: integerSchemaCases},
+	{name: "marshaler schema cases", schemaCases: marshalerSchemaCases},
+}
+
 func TestSchemaCases(t *testing.T) {
+	runSchemaCases(t, schemaCases)
+}
+
+func compileSchema(t *testing.T, schema ojson.Anything) *jsonschema.Schema {
+	schemaData := ojson.MustMarshal(schema)
+	compiled := new(jsonschema.Schema)
+	err := json.Unmarshal(schemaData, compiled)
+	require.NoError(t, err)
+	return compiled
+}
+
+// runSchemaCases validates every case and checks that neither marshaling,
+// compiling nor validating mutated the schema or the instances, comparing
+// them with deep copies taken beforehand.
+func runSchemaCases(t *testing.T, schemaCases []schemaCase) {
 	for _, schemaCase := range schemaCases {
 		t.Run(schemaCase.name, func(t *testing.T) {
+			schemaCopy := deepCopy(schemaCase.schema)
 			schemaData := ojson.MustMarshal(schemaCase.schema)
+			schemaDataCopy := append([]byte(nil), schemaData...)
 			schema := new(jsonschema.Schema)
 			err := json.Unmarshal(schemaData, schema)
 			require.NoError(t, err)
+			require.Equal(t, schemaDataCopy, schemaData, "unmarshaling mutated the schema data")
+			require.Equal(t, schemaCopy, schemaCase.schema, "marshaling mutated the schema")
 			for _, validationCase := range schemaCase.validationCases {
 				t.Run(validationCase.name, func(t *testing.T) {
+					actualCopy := deepCopy(validationCase.actual)
 					state := schema.Validate(context.Background(), validationCase.actual)
 					require.Equal(t, validationCase.expected, *state.Errs)
+					require.Equal(t, actualCopy, validationCase.actual, "validation mutated the instance")
 				})
 			}
+			require.Equal(t, schemaCopy, schemaCase.schema, "validation mutated the schema")
 		})
 	}
 }
@@ -0,0 +1,140 @@
+package ojsonschema_tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+type orderedMember struct {
+	key   string
+	value ojson.Anything
+}
+
+// orderedObject is a JSON object that marshals its members in the order
+// they are listed, for schemas where key order carries documentation
+// meaning. ojson.Object always marshals with sorted keys.
+type orderedObject []orderedMember
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	buffer := bytes.NewBufferString("{")
+	for i, member := range o {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, err := json.Marshal(member.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.value)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+var orderedSchemaCases = []schemaCase{
+	{
+		name: "ordered object: documented payment",
+		spec: []string{"core §9.3.2.1 properties", "validation §6.1.2 enum", "validation §6.5.3 required"},
+		schema: orderedObject{
+			{"title", "Payment"},
+			{"description", "Members are listed in reading order."},
+			{"type", "object"},
+			{"properties", orderedObject{
+				{"id", ojsonschema.String{}},
+				{"currency", ojsonschema.String{Enum: ojson.Array{"EUR", "USD"}}},
+				{"amount", ojson.Object{"type": "number"}},
+			}},
+			{"required", ojson.Array{"id", "currency"}},
+			{"additionalProperties", false},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "valid payment",
+				actual:   ojson.Object{"id": "p-1", "currency": "EUR", "amount": 10},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "unknown currency",
+				actual: ojson.Object{"id": "p-1", "currency": "GBP"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/currency",
+						InvalidValue: "GBP",
+						Message:      `should be one of ["EUR", "USD"]`,
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestOrderedSchemaCases(t *testing.T) {
+	runSchemaCases(t, orderedSchemaCases)
+}
+
+func TestOrderedObjectKeepsKeyOrder(t *testing.T) {
+	schema := orderedObject{
+		{"type", "object"},
+		{"properties", orderedObject{
+			{"z", ojson.Object{"type": "string", "description": "last by name"}},
+			{"a", ojson.Object{"type": "string"}},
+		}},
+	}
+	require.Equal(t,
+		`{"type":"object","properties":{"z":{"description":"last by name","type":"string"},"a":{"type":"string"}}}`,
+		string(ojson.MustMarshal(schema)),
+	)
+	require.Equal(t, `{}`, string(ojson.MustMarshal(orderedObject{})))
+}
+
+func TestObjectKeysAreSorted(t *testing.T) {
+	require.Equal(t,
+		`{"a":1,"b":{"c":3,"d":4},"z":2}`,
+		string(ojson.MustMarshal(ojson.Object{"z": 2, "b": ojson.Object{"d": 4, "c": 3}, "a": 1})),
+	)
+}
+
+const (
+	marshalGoroutines = 8
+	marshalRepeats    = 100
+)
+
+func TestSchemaMarshalingIsDeterministic(t *testing.T) {
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			t.Run(schemaSuite.name+"/"+schemaCase.name, func(t *testing.T) {
+				expected := ojson.MustMarshal(schemaCase.schema)
+				results := make([][][]byte, marshalGoroutines)
+				var wg sync.WaitGroup
+				for i := range results {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						for j := 0; j < marshalRepeats; j++ {
+							results[i] = append(results[i], ojson.MustMarshal(schemaCase.schema))
+						}
+					}(i)
+				}
+				wg.Wait()
+				for _, result := range results {
+					for _, actual := range result {
+						require.Equal(t, string(expected), string(actual))
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"github.com/gogolibs/ojson"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"testing"
+	"unicode/utf8"
+)
+
+// keywordFragment is a piece of schema together with the JSON Schema
+// semantics of its keywords: accepts reports whether value is valid against
+// them, given the whole schema the fragment ended up in.
+type keywordFragment struct {
+	name     string
+	spec     []string
+	keywords ojson.Object
+	accepts  func(schema ojson.Object, value interface{}) bool
+}
+
+func acceptsStrings(accepts func(s string) bool) func(ojson.Object, interface{}) bool {
+	return func(_ ojson.Object, value interface{}) bool {
+		s, ok := value.(string)
+		return !ok || accepts(s)
+	}
+}
+
+func acceptsNumbers(accepts func(f float64) bool) func(ojson.Object, interface{}) bool {
+	return func(_ ojson.Object, value interface{}) bool {
+		f, ok := value.(float64)
+		return !ok || accepts(f)
+	}
+}
+
+func acceptsObjects(accepts func(schema, o ojson.Object) bool) func(ojson.Object, interface{}) bool {
+	return func(schema ojson.Object, value interface{}) bool {
+		o, ok := value.(map[string]interface{})
+		return !ok || accepts(schema, o)
+	}
+}
+
+func acceptsArrays(accepts func(a []interface{}) bool) func(ojson.Object, interface{}) bool {
+	return func(_ ojson.Object, value interface{}) bool {
+		a, ok := value.([]interface{})
+		return !ok || accepts(a)
+	}
+}
+
+var keywordFragments = []keywordFragment{
+	{
+		name:     "type string",
+		spec:     []string{"validation §6.1.1 type"},
+		keywords: ojson.Object{"type": "string"},
+		accepts:  func(_ ojson.Object, value interface{}) bool { return exactType(value) == "string" },
+	},
+	{
+		name:     "type object",
+		spec:     []string{"validation §6.1.1 type"},
+		keywords: ojson.Object{"type": "object"},
+		accepts:  func(_ ojson.Object, value interface{}) bool { return exactType(value) == "object" },
+	},
+	{
+		name:     "type number",
+		spec:     []string{"validation §6.1.1 type"},
+		keywords: ojson.Object{"type": "number"},
+		accepts: func(_ ojson.Object, value interface{}) bool {
+			return exactType(value) == "number" || exactType(value) == "integer"
+		},
+	},
+	{
+		name:     "enum",
+		spec:     []string{"validation §6.1.2 enum"},
+		keywords: ojson.Object{"enum": ojson.Array{"a", "ab", 1}},
+		accepts: func(_ ojson.Object, value interface{}) bool {
+			return exactEqual(value, "a") || exactEqual(value, "ab") || exactEqual(value, 1)
+		},
+	},
+	{
+		name:     "const",
+		spec:     []string{"validation §6.1.3 const"},
+		keywords: ojson.Object{"const": "a"},
+		accepts:  func(_ ojson.Object, value interface{}) bool { return exactEqual(value, "a") },
+	},
+	{
+		name:     "required",
+		spec:     []string{"validation §6.5.3 required"},
+		keywords: ojson.Object{"required": ojson.Array{"field"}},
+		accepts: acceptsObjects(func(_, o ojson.Object) bool {
+			_, ok := o["field"]
+			return ok
+		}),
+	},
+	{
+		name:     "properties",
+		spec:     []string{"core §9.3.2.1 properties"},
+		keywords: ojson.Object{"properties": ojson.Object{"field": ojson.Object{"type": "string"}}},
+		accepts: acceptsObjects(func(_, o ojson.Object) bool {
+			field, ok := o["field"]
+			return !ok || exactType(field) == "string"
+		}),
+	},
+	{
+		name:     "additionalProperties false",
+		spec:     []string{"core §9.3.2.3 additionalProperties"},
+		keywords: ojson.Object{"additionalProperties": false},
+		accepts: acceptsObjects(func(schema, o ojson.Object) bool {
+			properties, _ := schema["properties"].(ojson.Object)
+			for key := range o {
+				if _, ok := properties[key]; !ok {
+					return false
+				}
+			}
+			return true
+		}),
+	},
+	{
+		name:     "minLength",
+		spec:     []string{"validation §6.3.2 minLength"},
+		keywords: ojson.Object{"minLength": 2},
+		accepts:  acceptsStrings(func(s string) bool { return utf8.RuneCountInString(s) >= 2 }),
+	},
+	{
+		name:     "maxLength",
+		spec:     []string{"validation §6.3.1 maxLength"},
+		keywords: ojson.Object{"maxLength": 3},
+		accepts:  acceptsStrings(func(s string) bool { return utf8.RuneCountInString(s) <= 3 }),
+	},
+	{
+		name:     "pattern",
+		spec:     []string{"validation §6.3.3 pattern"},
+		keywords: ojson.Object{"pattern": "^a"},
+		accepts:  acceptsStrings(regexp.MustCompile("^a").MatchString),
+	},
+	{
+		name:     "minimum",
+		spec:     []string{"validation §6.2.4 minimum"},
+		keywords: ojson.Object{"minimum": 1},
+		accepts:  acceptsNumbers(func(f float64) bool { return f >= 1 }),
+	},
+	{
+		name:     "maximum",
+		spec:     []string{"validation §6.2.2 maximum"},
+		keywords: ojson.Object{"maximum": 10},
+		accepts:  acceptsNumbers(func(f float64) bool { return f <= 10 }),
+	},
+	{
+		name:     "items",
+		spec:     []string{"core §9.3.1.1 items"},
+		keywords: ojson.Object{"items": ojson.Object{"type": "string"}},
+		accepts: acceptsArrays(func(a []interface{}) bool {
+			for _, item := range a {
+				if exactType(item) != "string" {
+					return false
+				}
+			}
+			return true
+		}),
+	},
+	{
+		name:     "minItems",
+		spec:     []string{"validation §6.4.2 minItems"},
+		keywords: ojson.Object{"minItems": 1},
+		accepts:  acceptsArrays(func(a []interface{}) bool { return len(a) >= 1 }),
+	},
+}
+
+// pairwiseInstances are candidate instances, as JSON, chosen so that every
+// fragment has some of them on both sides.
+var pairwiseInstances = []string{
+	`null`, `true`,
+	`""`, `"a"`, `"ab"`, `"abcd"`, `"b"`,
+	`0`, `1`, `1.5`, `11`,
+	`{}`, `{"field": "x"}`, `{"field": 1}`, `{"other": 1}`, `{"field": "x", "other": 1}`,
+	`[]`, `["a"]`, `[1]`,
+}
+
+type generatedInstance struct {
+	actual string
+	valid  bool
+}
+
+type generatedCase struct {
+	name      string
+	schema    ojson.Object
+	instances []generatedInstance
+}
+
+// generatePairwiseCases combines every pair of fragments that do not share a
+// keyword into a schema, and attaches every candidate instance with the
+// validity the fragments' semantics give it. Pairs no candidate satisfies,
+// such as type number and a string const, contradict each other and are
+// left out like those sharing a keyword.
+func generatePairwiseCases(fragments []keywordFragment, instances []string) []generatedCase {
+	var cases []generatedCase
+	for i, a := range fragments {
+		for _, b := range fragments[i+1:] {
+			schema := ojson.Object{}
+			compatible := true
+			for _, fragment := range []keywordFragment{a, b} {
+				for keyword, value := range fragment.keywords {
+					if _, ok := schema[keyword]; ok {
+						compatible = false
+					}
+					schema[keyword] = value
+				}
+			}
+			if !compatible {
+				continue
+			}
+			generated := generatedCase{name: a.name + " + " + b.name, schema: schema}
+			satisfiable := false
+			for _, instance := range instances {
+				value := mustUnmarshal(instance)
+				valid := a.accepts(schema, value) && b.accepts(schema, value)
+				satisfiable = satisfiable || valid
+				generated.instances = append(generated.instances, generatedInstance{actual: instance, valid: valid})
+			}
+			if satisfiable {
+				cases = append(cases, generated)
+			}
+		}
+	}
+	return cases
+}
+
+func TestPairwiseKeywordCombinations(t *testing.T) {
+	for _, generated := range generatePairwiseCases(keywordFragments, pairwiseInstances) {
+		t.Run(generated.name, func(t *testing.T) {
+			schema := compileSchema(t, generated.schema)
+			valid, invalid := 0, 0
+			for _, instance := range generated.instances {
+				errs, err := schema.ValidateBytes(context.Background(), []byte(instance.actual))
+				require.NoError(t, err)
+				require.Equal(t, instance.valid, len(errs) == 0, "%s: %v", instance.actual, errs)
+				if instance.valid {
+					valid++
+				} else {
+					invalid++
+				}
+			}
+			require.NotZero(t, valid, "no valid instance for %s", ojson.MustMarshal(generated.schema))
+			require.NotZero(t, invalid, "no invalid instance for %s", ojson.MustMarshal(generated.schema))
+		})
+	}
+}
+
+func TestGeneratePairwiseCases(t *testing.T) {
+	generated := generatePairwiseCases(keywordFragments, pairwiseInstances)
+	names := map[string]bool{}
+	for _, generatedCase := range generated {
+		names[generatedCase.name] = true
+	}
+	contradictory := []string{"type object + enum", "type object + const", "type number + const", "const + minLength"}
+	n := len(keywordFragments)
+	require.Len(t, generated, n*(n-1)/2-3-len(contradictory), "every pair but those of two type fragments and contradictory ones")
+	require.False(t, names["type string + type object"])
+	for _, name := range contradictory {
+		require.False(t, names[name], name)
+	}
+	require.True(t, names["enum + const"])
+	require.True(t, names["required + additionalProperties false"])
+
+	for _, generatedCase := range generated {
+		if generatedCase.name == "enum + const" {
+			require.JSONEq(t, `{"const": "a", "enum": ["a", "ab", 1]}`, string(ojson.MustMarshal(generatedCase.schema)))
+			for _, instance := range generatedCase.instances {
+				require.Equal(t, instance.actual == `"a"`, instance.valid, instance.actual)
+			}
+		}
+	}
+	require.Empty(t, generatePairwiseCases(keywordFragments[3:5], []string{`"b"`}), "enum + const without a valid candidate")
+}
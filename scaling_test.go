@@ -0,0 +1,186 @@
+package ojsonschema_tests
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func schemaWithProperties(n int) ojson.Anything {
+	properties := ojson.Object{}
+	for i := 0; i < n; i++ {
+		properties[fmt.Sprintf("field%d", i)] = ojsonschema.String{}
+	}
+	return ojsonschema.Object{Properties: properties}
+}
+
+func schemaWithEnum(n int) ojson.Anything {
+	enum := ojson.Array{}
+	for i := 0; i < n; i++ {
+		enum = append(enum, fmt.Sprintf("member%d", i))
+	}
+	return ojsonschema.String{Enum: enum}
+}
+
+// schemaWithRefChain refers through n definitions, each referring to the
+// next, before reaching a string schema.
+func schemaWithRefChain(n int) ojson.Anything {
+	defs := ojson.Object{fmt.Sprintf("d%d", n): ojsonschema.String{}}
+	for i := 0; i < n; i++ {
+		defs[fmt.Sprintf("d%d", i)] = ojson.Object{"$ref": fmt.Sprintf("#/$defs/d%d", i+1)}
+	}
+	return ojson.Object{"$defs": defs, "$ref": "#/$defs/d0"}
+}
+
+func schemaWithAnyOf(n int) ojson.Anything {
+	anyOf := ojson.Array{}
+	for i := 0; i < n; i++ {
+		anyOf = append(anyOf, ojsonschema.Const(fmt.Sprintf("member%d", i)))
+	}
+	return ojson.Object{"anyOf": anyOf}
+}
+
+// hugeSchemas generate schemas of the size our generated catalog schemas
+// reach, size being the number of properties, members or links. The
+// invalid instance fails only once all of the schema has been walked.
+// Where validating it grows superlinearly, superlinear says why.
+var hugeSchemas = []struct {
+	name        string
+	size        int
+	generate    func(n int) ojson.Anything
+	invalid     interface{}
+	superlinear string
+}{
+	{name: "properties", size: 10000, generate: schemaWithProperties, invalid: map[string]interface{}{"field0": 42.0}},
+	{
+		name:        "enum",
+		size:        1000,
+		generate:    schemaWithEnum,
+		invalid:     "member",
+		superlinear: "Enum.String, which the error message lists the members with, concatenates them one by one",
+	},
+	{name: "$ref chain", size: 1000, generate: schemaWithRefChain, invalid: 42.0},
+	{name: "anyOf", size: 1000, generate: schemaWithAnyOf, invalid: "member"},
+}
+
+// compileHugeSchema is what compiling a built schema costs: marshaling it
+// and unmarshaling the result into a jsonschema.Schema.
+func compileHugeSchema(schema ojson.Anything) (*jsonschema.Schema, error) {
+	compiled := new(jsonschema.Schema)
+	return compiled, json.Unmarshal(ojson.MustMarshal(schema), compiled)
+}
+
+// compileAndValidateHugeSchema compiles a schema and validates an invalid
+// instance against it once. qri resolves $ref on the first Validate, not
+// when unmarshaling, so only this follows a $ref chain.
+func compileAndValidateHugeSchema(schema ojson.Anything, invalid interface{}) (*jsonschema.Schema, error) {
+	compiled, err := compileHugeSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(*compiled.Validate(context.Background(), invalid).Errs) == 0 {
+		return nil, fmt.Errorf("%#v is valid", invalid)
+	}
+	return compiled, nil
+}
+
+// measureCompile returns the best time out of runs calls of compile, and
+// the bytes allocated by one.
+func measureCompile(compile func() (*jsonschema.Schema, error), runs int) (time.Duration, uint64, error) {
+	var best time.Duration
+	var allocated uint64
+	for i := 0; i < runs; i++ {
+		runtime.GC()
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		if _, err := compile(); err != nil {
+			return 0, 0, err
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if i == 0 || elapsed < best {
+			best = elapsed
+		}
+		allocated = after.TotalAlloc - before.TotalAlloc
+	}
+	return best, allocated, nil
+}
+
+var compileTiming = flag.Bool("compile-timing", false,
+	"also hold the compile time of huge schemas to linear growth, which is noisy under -race or load")
+
+// TestCompileScaling compiles every huge schema at an eighth of its size
+// and at full size, with and without a first validation, and flags growth
+// well beyond linear. Allocations are held to twice the linear growth, or
+// shown to still exceed it where hugeSchemas explain why. Time, which is
+// noisier, is only held to four times with -compile-timing;
+// BenchmarkCompileHugeSchemas reports it.
+func TestCompileScaling(t *testing.T) {
+	if testing.Short() {
+		t.Skip("compiles huge schemas")
+	}
+	const growth = 8
+	for _, hugeSchema := range hugeSchemas {
+		small, large := hugeSchema.generate(hugeSchema.size/growth), hugeSchema.generate(hugeSchema.size)
+		invalid, superlinear := hugeSchema.invalid, hugeSchema.superlinear
+		for name, measurement := range map[string]func(schema ojson.Anything) func() (*jsonschema.Schema, error){
+			"compile": func(schema ojson.Anything) func() (*jsonschema.Schema, error) {
+				return func() (*jsonschema.Schema, error) { return compileHugeSchema(schema) }
+			},
+			"compile and validate": func(schema ojson.Anything) func() (*jsonschema.Schema, error) {
+				return func() (*jsonschema.Schema, error) { return compileAndValidateHugeSchema(schema, invalid) }
+			},
+		} {
+			t.Run(hugeSchema.name+"/"+name, func(t *testing.T) {
+				smallTime, smallBytes, err := measureCompile(measurement(small), 5)
+				require.NoError(t, err)
+				largeTime, largeBytes, err := measureCompile(measurement(large), 5)
+				require.NoError(t, err)
+				t.Logf("size %d: %s, %d bytes; size %d: %s, %d bytes",
+					hugeSchema.size/growth, smallTime, smallBytes, hugeSchema.size, largeTime, largeBytes)
+				if name == "compile and validate" && superlinear != "" {
+					require.Greater(t, float64(largeBytes), float64(smallBytes)*growth*2,
+						"allocations no longer grow superlinearly: %s", superlinear)
+					return
+				}
+				require.Less(t, float64(largeBytes), float64(smallBytes)*growth*2, "allocations grow superlinearly")
+				if *compileTiming {
+					require.Less(t, float64(largeTime), float64(smallTime)*growth*4, "time grows superlinearly")
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCompileHugeSchemas(b *testing.B) {
+	for _, hugeSchema := range hugeSchemas {
+		for _, size := range []int{hugeSchema.size / 10, hugeSchema.size} {
+			schema := hugeSchema.generate(size)
+			b.Run(fmt.Sprintf("%s/%d", hugeSchema.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := compileHugeSchema(schema); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%s/%d/validated", hugeSchema.name, size), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := compileAndValidateHugeSchema(schema, hugeSchema.invalid); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package ojsonschema_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const validationSnapshotPath = "testdata/validation_snapshot.json"
+
+var updateSnapshot = flag.Bool("update-snapshot", false, "record actual validation output into "+validationSnapshotPath)
+
+// snapshotError is a KeyError as recorded in the snapshot. Unlike KeyError
+// it keeps zero invalid values, which omitempty would drop.
+type snapshotError struct {
+	PropertyPath string      `json:"propertyPath"`
+	InvalidValue interface{} `json:"invalidValue"`
+	Message      string      `json:"message"`
+}
+
+func snapshotErrors(errs []jsonschema.KeyError) []snapshotError {
+	recorded := []snapshotError{}
+	for _, err := range errs {
+		recorded = append(recorded, snapshotError{
+			PropertyPath: err.PropertyPath,
+			InvalidValue: err.InvalidValue,
+			Message:      err.Message,
+		})
+	}
+	return recorded
+}
+
+// collectValidationOutput validates every validation case and every empty
+// value against every schema suite, keyed by suite, schema case and case
+// name. Empty values are only matched on validity by TestEmptyValues, so
+// the snapshot is what pins their messages. The same goes for the cases
+// kept outside the suites: exact cases, whose instances qri is given as
+// bytes, pairwise combinations, additionalProperties states, Go values,
+// both raw and normalized, and Markdown examples.
+func collectValidationOutput(t *testing.T) map[string][]snapshotError {
+	output := map[string][]snapshotError{}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			schema := compileSchema(t, schemaCase.schema)
+			prefix := schemaSuite.name + "/" + schemaCase.name + "/"
+			for _, validationCase := range schemaCase.validationCases {
+				state := schema.Validate(context.Background(), validationCase.actual)
+				output[prefix+validationCase.name] = snapshotErrors(*state.Errs)
+			}
+			for _, emptyValue := range emptyValues {
+				state := schema.Validate(context.Background(), emptyValue.value)
+				output[prefix+"empty "+emptyValue.name] = snapshotErrors(*state.Errs)
+			}
+		}
+	}
+	validateBytes := func(key string, schema *jsonschema.Schema, data []byte) {
+		errs, err := schema.ValidateBytes(context.Background(), data)
+		require.NoError(t, err, key)
+		output[key] = snapshotErrors(errs)
+	}
+	for suiteName, exactCases := range map[string][]exactSchemaCase{
+		"exact number cases":  exactNumberCases,
+		"exact integer cases": exactIntegerCases,
+	} {
+		for _, exactCase := range exactCases {
+			schema := compileSchema(t, exactCase.schema)
+			for _, validationCase := range exactCase.validationCases {
+				validateBytes(suiteName+"/"+exactCase.name+"/"+validationCase.name, schema, []byte(validationCase.actual))
+			}
+		}
+	}
+	for _, generated := range generatePairwiseCases(keywordFragments, pairwiseInstances) {
+		schema := compileSchema(t, generated.schema)
+		for _, instance := range generated.instances {
+			validateBytes("pairwise/"+generated.name+"/"+instance.actual, schema, []byte(instance.actual))
+		}
+	}
+	for _, additionalPropertiesState := range additionalPropertiesStates {
+		schema := compileSchema(t, ojsonschema.Object{
+			AdditionalProperties: additionalPropertiesState.additionalProperties,
+			Properties: ojson.Object{
+				"field": ojsonschema.String{},
+			},
+		})
+		for _, validationCase := range additionalPropertiesState.validationCases {
+			state := schema.Validate(context.Background(), validationCase.actual)
+			output["additionalProperties states/"+additionalPropertiesState.name+"/"+validationCase.name] = snapshotErrors(*state.Errs)
+		}
+	}
+	for _, goValueCase := range goValueCases {
+		schema := compileSchema(t, goValueCase.schema)
+		state := schema.Validate(context.Background(), goValueCase.actual)
+		output["Go value cases/"+goValueCase.name+"/raw"] = snapshotErrors(*state.Errs)
+		errs, err := validateNormalized(context.Background(), schema, goValueCase.actual)
+		require.NoError(t, err, goValueCase.name)
+		output["Go value cases/"+goValueCase.name+"/normalized"] = snapshotErrors(errs)
+	}
+	examples, err := markdownFileExamples()
+	require.NoError(t, err)
+	for _, example := range examples {
+		schema := compileSchema(t, json.RawMessage(example.schema))
+		for _, instance := range example.instances {
+			validateBytes("Markdown/"+example.name+"/"+instance.name, schema, []byte(instance.data))
+		}
+	}
+	return output
+}
+
+func TestValidationSnapshot(t *testing.T) {
+	output := collectValidationOutput(t)
+	if *updateSnapshot {
+		data, err := json.MarshalIndent(output, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(validationSnapshotPath), 0755))
+		require.NoError(t, os.WriteFile(validationSnapshotPath, append(data, '\n'), 0644))
+		return
+	}
+	data, err := os.ReadFile(validationSnapshotPath)
+	require.NoError(t, err, "run go test -run TestValidationSnapshot -update-snapshot to record it")
+	recorded := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(data, &recorded))
+
+	drift := []string{}
+	for _, key := range sortedSnapshotKeys(output, recorded) {
+		actual := []byte("null")
+		if errs, ok := output[key]; ok {
+			actual, err = json.Marshal(errs)
+			require.NoError(t, err)
+		}
+		expected := new(bytes.Buffer)
+		if raw, ok := recorded[key]; ok {
+			require.NoError(t, json.Compact(expected, raw))
+		} else {
+			expected.WriteString("null")
+		}
+		if !bytes.Equal(expected.Bytes(), actual) {
+			drift = append(drift, fmt.Sprintf("%s:\n  recorded: %s\n  actual:   %s", key, expected, actual))
+		}
+	}
+	require.Empty(t, drift, "validation output drifted from %s, "+
+		"run go test -run TestValidationSnapshot -update-snapshot to accept it", validationSnapshotPath)
+}
+
+func sortedSnapshotKeys(output map[string][]snapshotError, recorded map[string]json.RawMessage) []string {
+	keys := map[string]interface{}{}
+	for key := range output {
+		keys[key] = nil
+	}
+	for key := range recorded {
+		keys[key] = nil
+	}
+	return sortedKeys(keys)
+}
@@ -0,0 +1,183 @@
+package ojsonschema_tests
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const traceabilityMatrixPath = "docs/traceability.md"
+
+var updateTraceability = flag.Bool("update-traceability", false, "regenerate "+traceabilityMatrixPath)
+
+// specRequirements are the JSON Schema draft 2019-09 sections that cases
+// can reference through their spec field, as "<document> §<section> <name>".
+var specRequirements = []string{
+	"core §4.2.1 instance data model",
+	"core §4.2.2 instance equality",
+	"core §8.2.4.1 $ref",
+	"core §9.2.1.1 allOf",
+	"core §9.2.1.2 anyOf",
+	"core §9.2.1.3 oneOf",
+	"core §9.2.1.4 not",
+	"core §9.2.2.1 if",
+	"core §9.2.2.4 dependentSchemas",
+	"core §9.3.1.1 items",
+	"core §9.3.1.2 additionalItems",
+	"core §9.3.1.4 contains",
+	"core §9.3.2.1 properties",
+	"core §9.3.2.2 patternProperties",
+	"core §9.3.2.3 additionalProperties",
+	"core §9.3.2.5 propertyNames",
+	"validation §6.1.1 type",
+	"validation §6.1.2 enum",
+	"validation §6.1.3 const",
+	"validation §6.2.1 multipleOf",
+	"validation §6.2.2 maximum",
+	"validation §6.2.3 exclusiveMaximum",
+	"validation §6.2.4 minimum",
+	"validation §6.2.5 exclusiveMinimum",
+	"validation §6.3.1 maxLength",
+	"validation §6.3.2 minLength",
+	"validation §6.3.3 pattern",
+	"validation §6.4.1 maxItems",
+	"validation §6.4.2 minItems",
+	"validation §6.4.3 uniqueItems",
+	"validation §6.4.4 maxContains",
+	"validation §6.4.5 minContains",
+	"validation §6.5.1 maxProperties",
+	"validation §6.5.2 minProperties",
+	"validation §6.5.3 required",
+	"validation §6.5.4 dependentRequired",
+	"validation §7 format",
+}
+
+// specCoverage maps every referenced requirement to the cases covering it.
+// A schema case covers the requirements it references for all of its
+// validation cases; a validation case only for itself. Besides the schema
+// suites, exact cases, pairwise fragments, additionalProperties states, Go
+// value cases and Markdown examples reference requirements too.
+func specCoverage() (map[string][]string, error) {
+	coverage := map[string][]string{}
+	cover := func(name string, spec []string) {
+		for _, requirement := range spec {
+			coverage[requirement] = append(coverage[requirement], name)
+		}
+	}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			prefix := schemaSuite.name + "/" + schemaCase.name
+			cover(prefix, schemaCase.spec)
+			for _, validationCase := range schemaCase.validationCases {
+				cover(prefix+"/"+validationCase.name, validationCase.spec)
+			}
+		}
+	}
+	for _, exactCase := range exactNumberCases {
+		cover("exact number cases/"+exactCase.name, exactCase.spec)
+	}
+	for _, exactCase := range exactIntegerCases {
+		cover("exact integer cases/"+exactCase.name, exactCase.spec)
+	}
+	for _, fragment := range keywordFragments {
+		cover("pairwise fragments/"+fragment.name, fragment.spec)
+	}
+	for _, additionalPropertiesState := range additionalPropertiesStates {
+		cover("additionalProperties states/"+additionalPropertiesState.name, additionalPropertiesState.spec)
+	}
+	for _, goValueCase := range goValueCases {
+		cover("Go value cases/"+goValueCase.name, goValueCase.spec)
+	}
+	examples, err := markdownFileExamples()
+	if err != nil {
+		return nil, err
+	}
+	for _, example := range examples {
+		cover(example.name, example.spec)
+	}
+	return coverage, nil
+}
+
+// traceabilityMatrix renders specCoverage as Markdown, listing covered
+// requirements with their cases and then the uncovered ones.
+func traceabilityMatrix() (string, error) {
+	coverage, err := specCoverage()
+	if err != nil {
+		return "", err
+	}
+	builder := new(strings.Builder)
+	builder.WriteString("# Specification traceability\n\n")
+	builder.WriteString("Generated by `go test -run TestTraceabilityMatrix -update-traceability`, do not edit.\n\n")
+	builder.WriteString("## Covered\n\n")
+	builder.WriteString("| Requirement | Cases |\n")
+	builder.WriteString("| --- | --- |\n")
+	uncovered := []string{}
+	for _, requirement := range specRequirements {
+		cases := coverage[requirement]
+		if len(cases) == 0 {
+			uncovered = append(uncovered, requirement)
+			continue
+		}
+		fmt.Fprintf(builder, "| %s | %s |\n", requirement, strings.Join(cases, "<br>"))
+	}
+	builder.WriteString("\n## Not covered\n\n")
+	for _, requirement := range uncovered {
+		fmt.Fprintf(builder, "- %s\n", requirement)
+	}
+	fmt.Fprintf(builder, "\n%d of %d requirements covered.\n",
+		len(specRequirements)-len(uncovered), len(specRequirements))
+	return builder.String(), nil
+}
+
+func TestSpecReferences(t *testing.T) {
+	known := map[string]bool{}
+	for _, requirement := range specRequirements {
+		require.False(t, known[requirement], "duplicate requirement %s", requirement)
+		known[requirement] = true
+	}
+	coverage, err := specCoverage()
+	require.NoError(t, err)
+	for requirement, cases := range coverage {
+		require.True(t, known[requirement], "unknown requirement %q referenced by %v", requirement, cases)
+	}
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			require.NotEmpty(t, schemaCase.spec, "%s/%s references no requirement", schemaSuite.name, schemaCase.name)
+		}
+	}
+	for _, exactCase := range append(append([]exactSchemaCase{}, exactNumberCases...), exactIntegerCases...) {
+		require.NotEmpty(t, exactCase.spec, "exact case %s references no requirement", exactCase.name)
+	}
+	for _, fragment := range keywordFragments {
+		require.NotEmpty(t, fragment.spec, "pairwise fragment %s references no requirement", fragment.name)
+	}
+	for _, additionalPropertiesState := range additionalPropertiesStates {
+		require.NotEmpty(t, additionalPropertiesState.spec, "additionalProperties state %s references no requirement", additionalPropertiesState.name)
+	}
+	for _, goValueCase := range goValueCases {
+		require.NotEmpty(t, goValueCase.spec, "Go value case %s references no requirement", goValueCase.name)
+	}
+	examples, err := markdownFileExamples()
+	require.NoError(t, err)
+	for _, example := range examples {
+		require.NotEmpty(t, example.spec, "Markdown example %s references no requirement", example.name)
+	}
+}
+
+func TestTraceabilityMatrix(t *testing.T) {
+	matrix, err := traceabilityMatrix()
+	require.NoError(t, err)
+	if *updateTraceability {
+		require.NoError(t, os.MkdirAll(filepath.Dir(traceabilityMatrixPath), 0755))
+		require.NoError(t, os.WriteFile(traceabilityMatrixPath, []byte(matrix), 0644))
+		return
+	}
+	data, err := os.ReadFile(traceabilityMatrixPath)
+	require.NoError(t, err)
+	require.Equal(t, string(data), matrix,
+		"%s is out of date, run go test -run TestTraceabilityMatrix -update-traceability", traceabilityMatrixPath)
+}
@@ -0,0 +1,467 @@
+package ojsonschema_tests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/stretchr/testify/require"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+)
+
+var streamDocumentSize = flag.Int("stream-document-size", 1<<20,
+	"size in bytes of the documents generated to check streaming validation")
+
+var streamLargeDocumentSize = flag.Int("stream-large-document-size", 0,
+	"size in bytes, 64 MiB or more, of a document streamed to check the heap stays far below it")
+
+// streamKeywords are the keywords validateStream evaluates, annotations
+// aside.
+var streamKeywords = map[string]bool{
+	"type": true, "enum": true, "const": true,
+	"required": true, "properties": true, "additionalProperties": true, "items": true,
+	"$schema": true, "$comment": true, "title": true, "description": true, "default": true, "examples": true,
+}
+
+// compileStreamSchema decodes a schema for validateStream, refusing the
+// keywords it does not evaluate rather than ignoring them.
+func compileStreamSchema(schema ojson.Anything) (interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal(ojson.MustMarshal(schema), &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, checkStreamKeywords(decoded, "")
+}
+
+func checkStreamKeywords(schema interface{}, path string) error {
+	if _, ok := schema.(bool); ok {
+		return nil
+	}
+	members, ok := schema.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: schema is not an object or a boolean", propertyPath(path))
+	}
+	for _, keyword := range sortedKeys(members) {
+		if !streamKeywords[keyword] {
+			return fmt.Errorf("%s: unsupported keyword %s", propertyPath(path), keyword)
+		}
+		switch keyword {
+		case "properties":
+			properties, ok := members[keyword].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s: properties is not an object", propertyPath(path))
+			}
+			for _, key := range sortedKeys(properties) {
+				if err := checkStreamKeywords(properties[key], childPath(childPath(path, keyword), key)); err != nil {
+					return err
+				}
+			}
+		case "additionalProperties", "items":
+			if err := checkStreamKeywords(members[keyword], childPath(path, keyword)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateStream validates the single JSON document read by decoder
+// against a schema from compileStreamSchema, holding in memory no more of
+// it than enum and const need to compare against. It stops reading at the
+// first failing keyword, so only a valid document is read to its end and
+// checked to be well-formed.
+func validateStream(schema interface{}, decoder *json.Decoder) (bool, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return false, err
+	}
+	valid, err := validateStreamValue(schema, decoder, token)
+	if err != nil || !valid {
+		return false, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return false, fmt.Errorf("unexpected data after top-level value")
+	}
+	return true, nil
+}
+
+// validateStreamValue validates the value starting with token.
+func validateStreamValue(schema interface{}, decoder *json.Decoder, token json.Token) (bool, error) {
+	if accepts, ok := schema.(bool); ok {
+		if accepts {
+			return true, skipStreamValue(decoder, token)
+		}
+		return false, nil
+	}
+	members := schema.(map[string]interface{})
+	_, hasEnum := members["enum"]
+	_, hasConst := members["const"]
+	if hasEnum || hasConst {
+		value, err := decodeStreamValue(decoder, token)
+		if err != nil {
+			return false, err
+		}
+		return fastValid(schema, value), nil
+	}
+	switch token {
+	case json.Delim('{'):
+		if expected, ok := members["type"]; ok && !fastTypeNameMatches(expected, "object") {
+			return false, nil
+		}
+		return validateStreamObject(members, decoder)
+	case json.Delim('['):
+		if expected, ok := members["type"]; ok && !fastTypeNameMatches(expected, "array") {
+			return false, nil
+		}
+		items, ok := members["items"]
+		if !ok {
+			items = true
+		}
+		for decoder.More() {
+			item, err := decoder.Token()
+			if err != nil {
+				return false, err
+			}
+			if valid, err := validateStreamValue(items, decoder, item); err != nil || !valid {
+				return false, err
+			}
+		}
+		_, err := decoder.Token()
+		return err == nil, err
+	}
+	return fastValid(schema, token), nil
+}
+
+func validateStreamObject(members map[string]interface{}, decoder *json.Decoder) (bool, error) {
+	missing := map[string]bool{}
+	if required, ok := members["required"].([]interface{}); ok {
+		for _, key := range required {
+			name, _ := key.(string)
+			missing[name] = true
+		}
+	}
+	properties, _ := members["properties"].(map[string]interface{})
+	additionalProperties, ok := members["additionalProperties"]
+	if !ok {
+		additionalProperties = true
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return false, err
+		}
+		key := token.(string)
+		delete(missing, key)
+		property, ok := properties[key]
+		if !ok {
+			property = additionalProperties
+		}
+		if token, err = decoder.Token(); err != nil {
+			return false, err
+		}
+		if valid, err := validateStreamValue(property, decoder, token); err != nil || !valid {
+			return false, err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return false, err
+	}
+	return len(missing) == 0, nil
+}
+
+// decodeStreamValue reads the value starting with token into the types
+// json.Unmarshal would give it.
+func decodeStreamValue(decoder *json.Decoder, token json.Token) (interface{}, error) {
+	switch token {
+	case json.Delim('{'):
+		object := map[string]interface{}{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			if object[key.(string)], err = decodeStreamValue(decoder, token); err != nil {
+				return nil, err
+			}
+		}
+		_, err := decoder.Token()
+		return object, err
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			item, err := decodeStreamValue(decoder, token)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+		_, err := decoder.Token()
+		return array, err
+	}
+	return token, nil
+}
+
+// skipStreamValue reads past the value starting with token.
+func skipStreamValue(decoder *json.Decoder, token json.Token) error {
+	depth := 0
+	for {
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+		var err error
+		if token, err = decoder.Token(); err != nil {
+			return err
+		}
+	}
+}
+
+func validateStreamBytes(schema interface{}, data []byte) (bool, error) {
+	return validateStream(schema, json.NewDecoder(bytes.NewReader(data)))
+}
+
+func TestStreamAgreesWithQri(t *testing.T) {
+	for _, schemaSuite := range schemaSuites {
+		for _, schemaCase := range schemaSuite.schemaCases {
+			t.Run(schemaSuite.name+"/"+schemaCase.name, func(t *testing.T) {
+				streamSchema, err := compileStreamSchema(schemaCase.schema)
+				if err != nil {
+					t.Skipf("not streamable: %s", err)
+				}
+				schema := compileSchema(t, schemaCase.schema)
+				instances := map[string]interface{}{}
+				for _, validationCase := range schemaCase.validationCases {
+					instances[validationCase.name] = validationCase.actual
+				}
+				for _, emptyValue := range emptyValues {
+					instances["empty "+emptyValue.name] = emptyValue.value
+				}
+				for name, instance := range instances {
+					data, err := json.Marshal(instance)
+					if err != nil {
+						continue
+					}
+					errs, err := schema.ValidateBytes(context.Background(), data)
+					require.NoError(t, err, name)
+					valid, err := validateStreamBytes(streamSchema, data)
+					require.NoError(t, err, name)
+					require.Equal(t, len(errs) == 0, valid, "%s: %s", name, data)
+				}
+			})
+		}
+	}
+	generatedCases := generatePairwiseCases(keywordFragments, pairwiseInstances)
+	skipped := 0
+	for _, generated := range generatedCases {
+		streamSchema, err := compileStreamSchema(generated.schema)
+		if err != nil {
+			skipped++
+			continue
+		}
+		schema := compileSchema(t, generated.schema)
+		for _, instance := range generated.instances {
+			errs, err := schema.ValidateBytes(context.Background(), []byte(instance.actual))
+			require.NoError(t, err)
+			valid, err := validateStreamBytes(streamSchema, []byte(instance.actual))
+			require.NoError(t, err)
+			require.Equal(t, len(errs) == 0, valid, "%s: %s", generated.name, instance.actual)
+		}
+	}
+	t.Logf("%d of %d pairwise schemas not streamable", skipped, len(generatedCases))
+	require.Less(t, skipped, len(generatedCases))
+	for _, schemaData := range booleanTypeSchemas {
+		streamSchema, err := compileStreamSchema(mustUnmarshal(schemaData))
+		require.NoError(t, err)
+		schema := compileSchema(t, json.RawMessage(schemaData))
+		for _, instance := range booleanTypeInstances {
+			errs, err := schema.ValidateBytes(context.Background(), []byte(instance))
+			require.NoError(t, err)
+			valid, err := validateStreamBytes(streamSchema, []byte(instance))
+			require.NoError(t, err)
+			require.Equal(t, len(errs) == 0, valid, "%s: %s", schemaData, instance)
+		}
+	}
+}
+
+func TestValidateStream(t *testing.T) {
+	schema, err := compileStreamSchema(ojson.Object{"items": ojson.Object{"enum": ojson.Array{ojson.Object{"a": 1}}}})
+	require.NoError(t, err)
+	for data, expected := range map[string]bool{
+		`[]`:                     true,
+		`[{"a": 1}, {"a": 1.0}]`: true,
+		`[{"a": 1}, {"a": 2}]`:   false,
+		`[{"a": 1}] `:            true,
+	} {
+		valid, err := validateStreamBytes(schema, []byte(data))
+		require.NoError(t, err, data)
+		require.Equal(t, expected, valid, data)
+	}
+	for _, data := range []string{`[{"a": 1}`, `[] []`, ``, `[{"a": 1}, {"a" 1}]`} {
+		_, err := validateStreamBytes(schema, []byte(data))
+		require.Error(t, err, data)
+	}
+	_, err = compileStreamSchema(ojson.Object{"properties": ojson.Object{"field": ojson.Object{"pattern": "^a"}}})
+	require.EqualError(t, err, "/properties/field: unsupported keyword pattern")
+}
+
+// generatedDocumentSchema describes the documents writeGeneratedDocument
+// writes.
+var generatedDocumentSchema = ojsonschema.Object{
+	AdditionalProperties: false,
+	Properties: ojson.Object{
+		"records": ojson.Object{
+			"type": "array",
+			"items": ojsonschema.Object{
+				AdditionalProperties: false,
+				Properties: ojson.Object{
+					"id":     ojsonschema.String{},
+					"status": ojsonschema.String{Enum: ojson.Array{"active", "inactive"}},
+					"tags":   ojson.Object{"type": "array", "items": ojsonschema.String{}},
+					"owner": ojsonschema.Object{
+						Properties: ojson.Object{"name": ojsonschema.String{}, "id": ojson.Object{"type": "integer"}},
+						Required:   ojson.Array{"name"},
+					},
+				},
+				Required: ojson.Array{"id", "status"},
+			},
+		},
+	},
+	Required: ojson.Array{"records"},
+}
+
+// writeGeneratedDocument writes a document of at least size bytes, all of
+// its records valid but, with invalidLast, the last one.
+func writeGeneratedDocument(w io.Writer, size int, invalidLast bool) error {
+	buffered := bufio.NewWriter(w)
+	written := 0
+	write := func(format string, args ...interface{}) {
+		n, _ := fmt.Fprintf(buffered, format, args...)
+		written += n
+	}
+	write(`{"records": [`)
+	for i := 0; written < size; i++ {
+		if i > 0 {
+			write(`, `)
+		}
+		write(`{"id": "record-%d", "status": %q, "tags": ["a", "b%d"], "owner": {"name": "owner %d", "id": %d}}`,
+			i, []string{"active", "inactive"}[i%2], i%7, i%13, i)
+	}
+	if invalidLast {
+		write(`, {"id": "record-last", "status": "archived"}`)
+	}
+	write(`]}`)
+	return buffered.Flush()
+}
+
+func TestStreamGeneratedDocuments(t *testing.T) {
+	if testing.Short() {
+		t.Skip("generates large documents")
+	}
+	streamSchema, err := compileStreamSchema(generatedDocumentSchema)
+	require.NoError(t, err)
+	schema := compileSchema(t, generatedDocumentSchema)
+	for _, invalidLast := range []bool{false, true} {
+		t.Run(fmt.Sprintf("invalid last %t", invalidLast), func(t *testing.T) {
+			reader, writer := io.Pipe()
+			go func() {
+				writer.CloseWithError(writeGeneratedDocument(writer, *streamDocumentSize, invalidLast))
+			}()
+			valid, err := validateStream(streamSchema, json.NewDecoder(reader))
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+
+			document := new(bytes.Buffer)
+			require.NoError(t, writeGeneratedDocument(document, *streamDocumentSize, invalidLast))
+			errs, err := schema.ValidateBytes(context.Background(), document.Bytes())
+			require.NoError(t, err)
+			require.Equal(t, len(errs) == 0, valid, "%v", errs)
+			require.Equal(t, !invalidLast, valid)
+		})
+	}
+}
+
+// peakHeapDuring runs f while sampling the heap, and returns the highest
+// heap seen above what was in use, after a GC, before f started.
+func peakHeapDuring(f func()) uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	baseline, peak := stats.HeapAlloc, stats.HeapAlloc
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak {
+				peak = stats.HeapAlloc
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	if peak < baseline {
+		return 0
+	}
+	return peak - baseline
+}
+
+// TestStreamLargeDocument streams a document of -stream-large-document-size
+// bytes, too large to hold for qri, and checks that the heap never grows
+// by more than a sixteenth of it, which buffering the document would.
+func TestStreamLargeDocument(t *testing.T) {
+	size := *streamLargeDocumentSize
+	if size == 0 {
+		t.Skip("run with -stream-large-document-size to stream a large document")
+	}
+	require.GreaterOrEqual(t, size, 64<<20, "the decoder and runtime take a few MiB whatever the size")
+	streamSchema, err := compileStreamSchema(generatedDocumentSchema)
+	require.NoError(t, err)
+	for _, invalidLast := range []bool{false, true} {
+		t.Run(fmt.Sprintf("invalid last %t", invalidLast), func(t *testing.T) {
+			var valid bool
+			var err error
+			peak := peakHeapDuring(func() {
+				reader, writer := io.Pipe()
+				go func() {
+					writer.CloseWithError(writeGeneratedDocument(writer, size, invalidLast))
+				}()
+				valid, err = validateStream(streamSchema, json.NewDecoder(reader))
+				_ = reader.Close()
+			})
+			require.NoError(t, err)
+			require.Equal(t, !invalidLast, valid)
+			t.Logf("%d bytes streamed, heap peaked %d bytes above baseline", size, peak)
+			require.Less(t, peak, uint64(size/16), "heap grew with the document")
+		})
+	}
+}
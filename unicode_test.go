@@ -0,0 +1,196 @@
+package ojsonschema_tests
+
+import (
+	"encoding/json"
+	"github.com/gogolibs/ojson"
+	"github.com/gogolibs/ojsonschema"
+	"github.com/qri-io/jsonschema"
+	"testing"
+)
+
+// mustUnmarshal decodes a JSON document the way ValidateBytes does, for
+// instances that only JSON text can express, such as unpaired surrogates.
+func mustUnmarshal(data string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		panic(err)
+	}
+	return value
+}
+
+const (
+	cafeNFC = "caf\u00e9"
+	cafeNFD = "cafe\u0301"
+)
+
+// unicodeSchemaCases pin down how property names and strings outside of
+// well-formed, normalized UTF-8 are matched.
+//
+// Property names are compared byte for byte, without Unicode normalization,
+// so "café" in NFD does not satisfy a required "café" in NFC and counts as
+// an additional property. Invalid UTF-8 and NUL bytes are kept as is in
+// in-memory instances; unpaired surrogates can only come from JSON text,
+// which encoding/json decodes to U+FFFD.
+var unicodeSchemaCases = []schemaCase{
+	{
+		name: "object: required non-ASCII property name, no additional properties",
+		spec: []string{"validation §6.5.3 required", "core §9.3.2.3 additionalProperties"},
+		schema: ojsonschema.Object{
+			AdditionalProperties: false,
+			Properties: ojson.Object{
+				cafeNFC: ojsonschema.String{},
+			},
+			Required: ojson.Array{cafeNFC},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "NFC spelling",
+				actual:   ojson.Object{cafeNFC: "hello"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "NFC spelling decoded from an escape",
+				actual:   mustUnmarshal(`{"caf\u00e9": "hello"}`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "NFD spelling",
+				spec:   []string{"core §4.2.2 instance equality"},
+				actual: ojson.Object{cafeNFD: "hello"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{cafeNFD: "hello"},
+						Message:      `"` + cafeNFC + `" value is required`,
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{cafeNFD: "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+			{
+				name:   "both spellings",
+				spec:   []string{"core §4.2.2 instance equality"},
+				actual: ojson.Object{cafeNFC: "hello", cafeNFD: "hello"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{cafeNFC: "hello", cafeNFD: "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+			{
+				name:   "Latin-1 spelling is invalid UTF-8",
+				actual: ojson.Object{"caf\xe9": "hello"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"caf\xe9": "hello"},
+						Message:      `"` + cafeNFC + `" value is required`,
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"caf\xe9": "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+			{
+				name:   "trailing NUL byte in property name",
+				actual: ojson.Object{cafeNFC + "\x00": "hello"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{cafeNFC + "\x00": "hello"},
+						Message:      `"` + cafeNFC + `" value is required`,
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{cafeNFC + "\x00": "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+			{
+				name:     "invalid UTF-8 in value",
+				actual:   ojson.Object{cafeNFC: "\xff\xfe"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "NUL byte in value",
+				actual:   ojson.Object{cafeNFC: "hello\x00world"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "unpaired surrogate in value",
+				actual:   mustUnmarshal(`{"caf\u00e9": "\ud800"}`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "unpaired surrogate in property name",
+				actual: mustUnmarshal(`{"\ud800": "hello"}`),
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"\ufffd": "hello"},
+						Message:      `"` + cafeNFC + `" value is required`,
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"\ufffd": "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+		},
+	},
+	{
+		name: "object: empty and NUL property names",
+		spec: []string{"validation §6.5.3 required", "core §9.3.2.3 additionalProperties"},
+		schema: ojsonschema.Object{
+			AdditionalProperties: false,
+			Properties: ojson.Object{
+				"":        ojsonschema.String{},
+				"nul\x00": ojsonschema.String{},
+			},
+			Required: ojson.Array{"", "nul\x00"},
+		},
+		emptyAccepted: []string{},
+		validationCases: []validationCase{
+			{
+				name:     "both present",
+				actual:   ojson.Object{"": "hello", "nul\x00": "hello"},
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:     "both present, decoded from escapes",
+				actual:   mustUnmarshal(`{"": "hello", "nul\u0000": "hello"}`),
+				expected: []jsonschema.KeyError{},
+			},
+			{
+				name:   "NUL byte missing from property name",
+				actual: ojson.Object{"": "hello", "nul": "hello"},
+				expected: []jsonschema.KeyError{
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"": "hello", "nul": "hello"},
+						Message:      `"nul` + "\x00" + `" value is required`,
+					},
+					{
+						PropertyPath: "/",
+						InvalidValue: map[string]interface{}{"": "hello", "nul": "hello"},
+						Message:      "additional properties are not allowed",
+					},
+				},
+			},
+		},
+	},
+}
+
+func TestUnicodeSchemaCases(t *testing.T) {
+	runSchemaCases(t, unicodeSchemaCases)
+}